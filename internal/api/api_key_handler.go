@@ -0,0 +1,131 @@
+package api
+
+import (
+	"api_server/internal/domain"
+	"api_server/internal/service"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *service.APIKeyService
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=255"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyResponse — ключ без секрета, с правами в виде списка
+type APIKeyResponse struct {
+	domain.APIKey
+	Scopes []string `json:"scopes"`
+}
+
+// CreatedAPIKeyResponse содержит сам ключ: он показывается только при создании
+type CreatedAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+func NewAPIKeyHandler(s *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyService: s}
+}
+
+func newAPIKeyResponse(key domain.APIKey) APIKeyResponse {
+	return APIKeyResponse{APIKey: key, Scopes: key.ScopeList()}
+}
+
+// CreateAPIKey godoc
+// @Summary      Создание API-ключа
+// @Description  Ключ возвращается в поле key только в этом ответе; передавайте его в заголовке X-API-Key.
+// @Tags         api-keys
+// @Accept       json
+// @Produce      json
+// @Param        request   body      CreateAPIKeyRequest  true  "JSON"
+// @Success      201       {object}  Response{data=CreatedAPIKeyResponse}
+// @Failure      400       {object}  Response
+// @Failure      401       {object}  Response
+// @Failure      403       {object}  Response
+// @Failure      500       {object}  Response
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var request CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(fmt.Errorf("%w: %v", errMalformedRequest, err))
+		return
+	}
+	if err := validate.Struct(request); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	key, secret, err := h.apiKeyService.CreateKey(c.Request.Context(), request.Name, request.Scopes, request.ExpiresAt)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	writeSuccessResponse(c, http.StatusCreated, CreatedAPIKeyResponse{
+		APIKeyResponse: newAPIKeyResponse(*key),
+		Key:            secret,
+	})
+}
+
+// GetAPIKeys godoc
+// @Summary      Список API-ключей
+// @Tags         api-keys
+// @Produce      json
+// @Success      200  {object}  Response{data=[]APIKeyResponse}
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      500  {object}  Response
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api-keys [get]
+func (h *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.GetKeys(c.Request.Context())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response := make([]APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, newAPIKeyResponse(key))
+	}
+	writeSuccessResponse(c, http.StatusOK, response)
+}
+
+// RevokeAPIKey godoc
+// @Summary      Отзыв API-ключа
+// @Tags         api-keys
+// @Produce      json
+// @Param        id   path      int  true  "ID ключа"
+// @Success      200  {object}  Response
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      404  {object}  Response
+// @Failure      500  {object}  Response
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		_ = c.Error(service.ErrIDNotValid)
+		return
+	}
+
+	if err := h.apiKeyService.RevokeKey(c.Request.Context(), uint(id)); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	writeSuccessResponse(c, http.StatusOK, nil)
+}
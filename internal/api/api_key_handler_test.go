@@ -0,0 +1,170 @@
+package api
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/domain"
+	"api_server/internal/repository/memory"
+	"api_server/internal/service"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var (
+	apiKeys       = service.NewAPIKeyService(memory.NewMockMemoryAPIKeyRepository(), policy)
+	apiKeyHandler = NewAPIKeyHandler(apiKeys)
+)
+
+func newAPIKeyRouter() http.Handler {
+	r := newTestRouter()
+	protected := r.Group("/", Authenticate(tokens, apiKeys))
+	protected.GET("/user/:id", RequireScope(auth.ScopeUsersRead), handler.GetUser)
+	protected.DELETE("/user/:id", RequireScope(auth.ScopeUsersDelete), handler.DeleteUser)
+
+	keys := protected.Group("/api-keys", RequireScope(auth.ScopeKeysManage))
+	keys.POST("", apiKeyHandler.CreateAPIKey)
+	keys.GET("", apiKeyHandler.GetAPIKeys)
+	keys.DELETE("/:id", apiKeyHandler.RevokeAPIKey)
+	return r
+}
+
+func createAPIKey(t *testing.T, r http.Handler, body string) *httptest.ResponseRecorder {
+	token := accessToken(t, 1, domain.RoleAdmin)
+	req, err := http.NewRequest(http.MethodPost, "/api-keys", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIKeyHandler_CreateAPIKey(t *testing.T) {
+	r := newAPIKeyRouter()
+	w := createAPIKey(t, r, `{"name": "reports", "scopes": ["users:read", "users:read"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusCreated)
+	}
+	var created CreatedAPIKeyResponse
+	err := decodeData(w.Body.Bytes(), &created)
+	if err != nil {
+		t.Errorf("Error unmarshalling response body: %v", err)
+	}
+	if !strings.HasPrefix(created.Key, "ak_"+created.Prefix+"_") {
+		t.Errorf("handler returned unexpected key: got %v", created.Key)
+	}
+	if len(created.Scopes) != 1 || created.Scopes[0] != auth.ScopeUsersRead {
+		t.Errorf("handler returned unexpected scopes: got %v", created.Scopes)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/api-keys", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(APIKeyHeader, created.Key)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusForbidden)
+	}
+	if strings.Contains(w.Body.String(), created.Key) {
+		t.Errorf("handler leaked key secret: %v", w.Body.String())
+	}
+}
+
+func TestAPIKeyHandler_CreateAPIKeyUnknownScope(t *testing.T) {
+	w := createAPIKey(t, newAPIKeyRouter(), `{"name": "reports", "scopes": ["users:everything"]}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusBadRequest)
+	}
+	var response Response
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Errorf("Error unmarshalling response body: %v", err)
+	}
+	if response.Error == nil || len(response.Error.Fields) != 1 || response.Error.Fields[0].Field != "scopes" {
+		t.Errorf("handler returned unexpected body: got %v", w.Body.String())
+	}
+}
+
+func TestAPIKeyHandler_ScopesAndRevoke(t *testing.T) {
+	r := newAPIKeyRouter()
+	w := createAPIKey(t, r, `{"name": "reader", "scopes": ["users:read"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusCreated)
+	}
+	var created CreatedAPIKeyResponse
+	if err := decodeData(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Error unmarshalling response body: %v", err)
+	}
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(APIKeyHeader, created.Key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodGet, "/user/1"); w.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+	w = do(http.MethodDelete, "/user/1")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusForbidden)
+	}
+	var response Response
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Errorf("Error unmarshalling response body: %v", err)
+	}
+	if response.Error == nil || response.Error.Code != CodeInsufficientScope {
+		t.Errorf("handler returned unexpected body: got %v want %v", w.Body.String(), CodeInsufficientScope)
+	}
+
+	keys, err := apiKeys.GetKeys(t.Context())
+	if err != nil || len(keys) == 0 || keys[len(keys)-1].LastUsedAt == nil {
+		t.Errorf("last used time was not recorded: %v %v", keys, err)
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, "/api-keys/"+strconv.FormatUint(uint64(created.ID), 10), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken(t, 1, domain.RoleAdmin))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+
+	w = do(http.MethodGet, "/user/1")
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusUnauthorized)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Errorf("Error unmarshalling response body: %v", err)
+	}
+	if response.Error == nil || response.Error.Code != CodeInvalidAPIKey {
+		t.Errorf("handler returned unexpected body: got %v want %v", w.Body.String(), CodeInvalidAPIKey)
+	}
+}
+
+func TestAPIKeyHandler_MemberForbidden(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "/api-keys", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken(t, 3, domain.RoleMember))
+	w := httptest.NewRecorder()
+	newAPIKeyRouter().ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusForbidden)
+	}
+}
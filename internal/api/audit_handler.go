@@ -0,0 +1,82 @@
+package api
+
+import (
+	"api_server/internal/repository"
+	"api_server/internal/service"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type AuditHandler struct {
+	auditService *service.AuditService
+	config       Config
+}
+
+type ListAuditRequest struct {
+	UserID *uint      `form:"user_id"`
+	Actor  string     `form:"actor"`
+	From   *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To     *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit  int        `form:"limit" validate:"omitempty,min=1"`
+	Offset int        `form:"offset" validate:"omitempty,min=0"`
+}
+
+func NewAuditHandler(s *service.AuditService, config Config) *AuditHandler {
+	return &AuditHandler{auditService: s, config: config}
+}
+
+// GetAuditRecords godoc
+// @Summary      Журнал аудита изменений пользователей
+// @Description  Записи возвращаются от новых к старым. Доступно только администраторам.
+// @Tags         audit
+// @Produce      json
+// @Param        user_id  query     int     false  "ID изменённого пользователя"
+// @Param        actor    query     string  false  "Кто выполнил действие, например user:1 или api-key:2"
+// @Param        from     query     string  false  "Не раньше (RFC 3339)"
+// @Param        to       query     string  false  "Не позже (RFC 3339)"
+// @Param        limit    query     int     false  "Размер страницы"
+// @Param        offset   query     int     false  "Смещение"
+// @Success      200  {object}  Response{data=[]domain.AuditRecord}
+// @Header       200  {int}     X-Total-Count  "Общее количество записей"
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      500  {object}  Response
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /audit [get]
+func (h *AuditHandler) GetAuditRecords(c *gin.Context) {
+	var request ListAuditRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		_ = c.Error(fmt.Errorf("%w: %v", errInvalidQuery, err))
+		return
+	}
+	if err := validate.Struct(request); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	query := repository.AuditQuery{
+		UserID: request.UserID,
+		Actor:  request.Actor,
+		From:   request.From,
+		To:     request.To,
+		Limit:  h.config.limit(request.Limit),
+		Offset: request.Offset,
+	}
+	records, total, err := h.auditService.GetRecords(c.Request.Context(), query)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	writeListResponse(c, http.StatusOK, records, Pagination{
+		Limit:  query.Limit,
+		Offset: query.Offset,
+		Total:  &total,
+	})
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"api_server/internal/domain"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var auditHandler = NewAuditHandler(audit, Config{})
+
+func TestAuditHandler_GetAuditRecords(t *testing.T) {
+	admin := accessToken(t, 1, domain.RoleAdmin)
+	member := accessToken(t, 3, domain.RoleMember)
+	defer func() {
+		_, _ = svc.ChangeRole(context.Background(), 3, domain.RoleMember, 0)
+	}()
+
+	r := newTestRouter()
+	r.Use(RequestID())
+	protected := r.Group("/", Authenticate(tokens, apiKeys))
+	protected.PATCH("/user/:id", handler.UpdateUser)
+	protected.GET("/audit", auditHandler.GetAuditRecords)
+
+	req, err := http.NewRequest(http.MethodPatch, "/user/3", strings.NewReader(`{"role": "manager"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+admin)
+	req.Header.Set(RequestIDHeader, "audit-test")
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+
+	req, err = http.NewRequest(http.MethodGet, "/audit?user_id=3&actor=user:1&limit=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+admin)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+	var records []domain.AuditRecord
+	if err := decodeData(w.Body.Bytes(), &records); err != nil {
+		t.Fatalf("Error unmarshalling response body: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("handler returned unexpected records: got %v", w.Body.String())
+	}
+	got := records[0]
+	if got.Action != domain.AuditUserUpdate || got.UserID != 3 || got.Actor != "user:1" ||
+		got.RequestID != "audit-test" || got.IP == "" {
+		t.Errorf("handler returned unexpected record: got %+v", got)
+	}
+	if change := got.Changes["role"]; change.From != string(domain.RoleMember) || change.To != string(domain.RoleManager) {
+		t.Errorf("handler returned unexpected changes: got %+v", got.Changes)
+	}
+	if _, ok := got.Changes["name"]; ok {
+		t.Errorf("unchanged field recorded: got %+v", got.Changes)
+	}
+
+	req, err = http.NewRequest(http.MethodGet, "/audit", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+member)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusForbidden)
+	}
+}
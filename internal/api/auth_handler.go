@@ -0,0 +1,167 @@
+package api
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/service"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+const (
+	userIDKey = "user_id"
+
+	APIKeyHeader = "X-API-Key"
+)
+
+type AuthHandler struct {
+	authService *service.AuthService
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+func NewAuthHandler(s *service.AuthService) *AuthHandler {
+	return &AuthHandler{authService: s}
+}
+
+// Login godoc
+// @Summary      Вход по email и паролю
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request   body      LoginRequest  true  "JSON"
+// @Success      200       {object}  Response{data=auth.TokenPair}
+// @Failure      400       {object}  Response
+// @Failure      401       {object}  Response
+// @Failure      429       {object}  Response
+// @Failure      500       {object}  Response
+// @Router       /auth/login [post]
+func (h *AuthHandler) Login(c *gin.Context) {
+	var request LoginRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(fmt.Errorf("%w: %v", errMalformedRequest, err))
+		return
+	}
+	if err := validate.Struct(request); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	tokens, err := h.authService.Login(c.Request.Context(), request.Email, request.Password)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	writeSuccessResponse(c, http.StatusOK, tokens)
+}
+
+// Refresh godoc
+// @Summary      Обновление пары токенов по refresh-токену
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request   body      RefreshRequest  true  "JSON"
+// @Success      200       {object}  Response{data=auth.TokenPair}
+// @Failure      400       {object}  Response
+// @Failure      401       {object}  Response
+// @Failure      500       {object}  Response
+// @Router       /auth/refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var request RefreshRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(fmt.Errorf("%w: %v", errMalformedRequest, err))
+		return
+	}
+	if err := validate.Struct(request); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	tokens, err := h.authService.Refresh(c.Request.Context(), request.RefreshToken)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	writeSuccessResponse(c, http.StatusOK, tokens)
+}
+
+// Authenticate пропускает только аутентифицированные запросы: с API-ключом
+// в заголовке X-API-Key или с действующим access-токеном в заголовке
+// "Authorization: Bearer <token>". Субъект запроса сохраняется в контексте
+// запроса (см. auth.PrincipalFromContext), ID пользователя — ещё и в контексте gin.
+func Authenticate(tokens *auth.TokenManager, keys *service.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rawKey := c.GetHeader(APIKeyHeader); rawKey != "" {
+			key, err := keys.Authenticate(c.Request.Context(), rawKey)
+			if err != nil {
+				_ = c.Error(err)
+				c.Abort()
+				return
+			}
+			setPrincipal(c, auth.Principal{APIKeyID: key.ID, Scopes: key.ScopeList()})
+			c.Next()
+			return
+		}
+
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			abortUnauthorized(c, errAuthenticationRequired)
+			return
+		}
+
+		claims, err := tokens.Parse(token, auth.TokenTypeAccess)
+		if err != nil {
+			abortUnauthorized(c, err)
+			return
+		}
+		userID, err := claims.UserID()
+		if err != nil {
+			abortUnauthorized(c, err)
+			return
+		}
+
+		c.Set(userIDKey, userID)
+		setPrincipal(c, auth.Principal{UserID: userID, Session: claims.Session})
+		c.Next()
+	}
+}
+
+// RequireScope пропускает запрос, только если у субъекта есть право scope.
+// Пользователи, вошедшие по JWT, ограничений по правам не имеют.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.PrincipalFromContext(c.Request.Context())
+		if !ok {
+			abortUnauthorized(c, errAuthenticationRequired)
+			return
+		}
+		if !principal.HasScope(scope) {
+			_ = c.Error(insufficientScope(scope))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func insufficientScope(scope string) error {
+	return &service.ForbiddenError{Err: fmt.Errorf("%w: %s", auth.ErrInsufficientScope, scope)}
+}
+
+func setPrincipal(c *gin.Context, p auth.Principal) {
+	c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), p))
+}
+
+func abortUnauthorized(c *gin.Context, err error) {
+	c.Header("WWW-Authenticate", `Bearer realm="api"`)
+	_ = c.Error(&service.UnauthorizedError{Err: err})
+	c.Abort()
+}
@@ -0,0 +1,267 @@
+package api
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/domain"
+	"api_server/internal/service"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	tokens, _   = auth.NewTokenManager("test-secret", time.Minute, time.Hour)
+	authHandler = NewAuthHandler(service.NewAuthService(repo, tokens))
+)
+
+// accessToken назначает пользователю роль и выдаёт ему access-токен
+func accessToken(t *testing.T, userID uint, role domain.Role) string {
+	user, err := svc.ChangeRole(context.Background(), userID, role, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tokens.Issue(userID, user.SessionVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pair.AccessToken
+}
+
+func login(t *testing.T, email, password string) *httptest.ResponseRecorder {
+	r := newTestRouter()
+	r.POST("/auth/login", authHandler.Login)
+	jsonBody := `{"email": "` + email + `", "password": "` + password + `"}`
+	req, err := http.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthHandler_LoginSuccess(t *testing.T) {
+	w := login(t, "test_2@example.com", testPassword)
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+	var pair auth.TokenPair
+	err := decodeData(w.Body.Bytes(), &pair)
+	if err != nil {
+		t.Errorf("Error unmarshalling response body: %v", err)
+	}
+	claims, err := tokens.Parse(pair.AccessToken, auth.TokenTypeAccess)
+	if err != nil {
+		t.Fatalf("handler returned invalid access token: %v", err)
+	}
+	if id, _ := claims.UserID(); id != 2 {
+		t.Errorf("access token issued for wrong user: got %v want %v", id, 2)
+	}
+	if _, err := tokens.Parse(pair.RefreshToken, auth.TokenTypeAccess); err == nil {
+		t.Errorf("refresh token accepted as access token")
+	}
+}
+
+func TestAuthHandler_LoginInvalidCredentials(t *testing.T) {
+	for _, email := range []string{"test_2@example.com", "nobody@example.com"} {
+		w := login(t, email, "wrong-password")
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", email, w.Code, http.StatusUnauthorized)
+		}
+		var response Response
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		if err != nil {
+			t.Errorf("Error unmarshalling response body: %v", err)
+		}
+		if response.Error == nil || response.Error.Code != CodeInvalidCredentials {
+			t.Errorf("%s: handler returned unexpected body: got %v", email, w.Body.String())
+		}
+	}
+}
+
+func TestAuthHandler_Refresh(t *testing.T) {
+	var pair auth.TokenPair
+	if err := decodeData(login(t, "test_3@example.com", testPassword).Body.Bytes(), &pair); err != nil {
+		t.Fatal(err)
+	}
+
+	r := newTestRouter()
+	r.POST("/auth/refresh", authHandler.Refresh)
+	tests := []struct {
+		token    string
+		wantCode int
+	}{
+		{pair.RefreshToken, http.StatusOK},
+		{pair.AccessToken, http.StatusUnauthorized},
+		{"broken", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(`{"refresh_token": "`+tt.token+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.wantCode {
+			t.Errorf("handler returned wrong status code: got %v want %v", w.Code, tt.wantCode)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	pair, err := tokens.Issue(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _ := auth.NewTokenManager("test-secret", time.Nanosecond, time.Nanosecond)
+	expiredPair, err := expired.Issue(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+
+	r := newTestRouter()
+	r.GET("/user/:id", Authenticate(tokens, apiKeys), handler.GetUser)
+	tests := []struct {
+		authorization string
+		wantCode      int
+		wantError     ErrorCode
+	}{
+		{"", http.StatusUnauthorized, CodeUnauthorized},
+		{"Bearer broken", http.StatusUnauthorized, CodeUnauthorized},
+		{"Bearer " + pair.RefreshToken, http.StatusUnauthorized, CodeUnauthorized},
+		{"Bearer " + expiredPair.AccessToken, http.StatusUnauthorized, CodeTokenExpired},
+		{"Bearer " + pair.AccessToken, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, "/user/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", tt.authorization)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.wantCode {
+			t.Errorf("%q: handler returned wrong status code: got %v want %v", tt.authorization, w.Code, tt.wantCode)
+			continue
+		}
+		if tt.wantError == "" {
+			continue
+		}
+		var response Response
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		if err != nil {
+			t.Errorf("Error unmarshalling response body: %v", err)
+		}
+		if response.Error == nil || response.Error.Code != tt.wantError {
+			t.Errorf("%q: handler returned unexpected body: got %v want %v", tt.authorization, w.Body.String(), tt.wantError)
+		}
+	}
+}
+
+func TestAuthorization_Roles(t *testing.T) {
+	admin := accessToken(t, 1, domain.RoleAdmin)
+	manager := accessToken(t, 2, domain.RoleManager)
+	member := accessToken(t, 3, domain.RoleMember)
+	defer func() {
+		_, _ = svc.ChangeRole(context.Background(), 3, domain.RoleMember, 0)
+	}()
+
+	r := newTestRouter()
+	protected := r.Group("/", Authenticate(tokens, apiKeys))
+	protected.GET("/user/:id", handler.GetUser)
+	protected.PATCH("/user/:id", handler.UpdateUser)
+	protected.DELETE("/user/:id", handler.DeleteUser)
+	protected.GET("/users", handler.GetUsers)
+
+	tests := []struct {
+		name     string
+		token    string
+		method   string
+		path     string
+		body     string
+		wantCode int
+	}{
+		{"member reads self", member, http.MethodGet, "/user/3", "", http.StatusOK},
+		{"member reads other", member, http.MethodGet, "/user/2", "", http.StatusForbidden},
+		{"member lists", member, http.MethodGet, "/users", "", http.StatusForbidden},
+		{"member updates other", member, http.MethodPatch, "/user/2", `{"age": 30}`, http.StatusForbidden},
+		{"member changes own role", member, http.MethodPatch, "/user/3", `{"role": "admin"}`, http.StatusForbidden},
+		{"member deletes self", member, http.MethodDelete, "/user/3", "", http.StatusForbidden},
+		{"manager lists", manager, http.MethodGet, "/users", "", http.StatusOK},
+		{"manager reads other", manager, http.MethodGet, "/user/3", "", http.StatusOK},
+		{"manager updates other", manager, http.MethodPatch, "/user/3", `{"age": 30}`, http.StatusForbidden},
+		{"manager deletes other", manager, http.MethodDelete, "/user/3", "", http.StatusForbidden},
+		{"admin changes role", admin, http.MethodPatch, "/user/3", `{"role": "manager"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tt.name, w.Code, tt.wantCode)
+			continue
+		}
+		if tt.wantCode != http.StatusForbidden {
+			continue
+		}
+		var response Response
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		if err != nil {
+			t.Errorf("Error unmarshalling response body: %v", err)
+		}
+		if response.Error == nil || response.Error.Code != CodeForbidden {
+			t.Errorf("%s: handler returned unexpected body: got %v want %v", tt.name, w.Body.String(), CodeForbidden)
+		}
+	}
+
+	user, err := svc.GetUserByID(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != domain.RoleManager {
+		t.Errorf("role was not changed: got %v want %v", user.Role, domain.RoleManager)
+	}
+}
+
+func TestAuthorization_APIKeyCannotChangeRole(t *testing.T) {
+	user, err := svc.CreateUser(t.Context(), "Role target", "role_target@example.com", 30, testPassword, domain.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = svc.PurgeUser(context.Background(), user.ID, 0)
+	})
+
+	r := newTestRouter()
+	r.PATCH("/user/:id", func(c *gin.Context) {
+		setPrincipal(c, auth.Principal{APIKeyID: 1, Scopes: []string{auth.ScopeUsersWrite}})
+	}, handler.UpdateUser)
+	req, err := http.NewRequest(http.MethodPatch, fmt.Sprintf("/user/%d", user.ID), strings.NewReader(`{"name": "Promoted", "role": "admin"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusForbidden)
+	}
+
+	after, err := svc.GetUserByID(t.Context(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Role != domain.RoleMember || after.Name != user.Name || after.Version != user.Version {
+		t.Errorf("user was changed: got %+v", after)
+	}
+}
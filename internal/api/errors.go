@@ -0,0 +1,207 @@
+package api
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/repository"
+	"api_server/internal/service"
+	"encoding/json"
+	"errors"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+)
+
+type ErrorCode string
+
+// Каталог кодов ошибок API. Коды стабильны: клиенты могут ветвиться по ним,
+// не разбирая текст сообщения.
+const (
+	CodeNotFound        ErrorCode = "NOT_FOUND"
+	CodeUserNotFound    ErrorCode = "USER_NOT_FOUND"
+	CodeAPIKeyNotFound  ErrorCode = "API_KEY_NOT_FOUND"
+	CodeVersionNotFound ErrorCode = "VERSION_NOT_FOUND"
+	CodeExportNotFound  ErrorCode = "EXPORT_NOT_FOUND"
+	CodeExportNotReady  ErrorCode = "EXPORT_NOT_READY"
+	CodeEmailTaken      ErrorCode = "EMAIL_ALREADY_EXISTS"
+	CodeEmailVerified   ErrorCode = "EMAIL_ALREADY_VERIFIED"
+	CodeUserNotDeleted  ErrorCode = "USER_NOT_DELETED"
+	CodeConflict        ErrorCode = "CONFLICT"
+	CodeUnprocessable   ErrorCode = "UNPROCESSABLE"
+
+	CodeIdempotencyKeyReused     ErrorCode = "IDEMPOTENCY_KEY_REUSED"
+	CodeIdempotencyKeyInProgress ErrorCode = "IDEMPOTENCY_KEY_IN_PROGRESS"
+	CodeInvalidIdempotencyKey    ErrorCode = "INVALID_IDEMPOTENCY_KEY"
+
+	CodePreconditionFailed ErrorCode = "PRECONDITION_FAILED"
+	CodeValidationFailed   ErrorCode = "VALIDATION_FAILED"
+	CodeInvalidID          ErrorCode = "INVALID_ID"
+	CodeInvalidVersion     ErrorCode = "INVALID_VERSION"
+	CodeMalformedRequest   ErrorCode = "MALFORMED_REQUEST"
+	CodeInvalidQuery       ErrorCode = "INVALID_QUERY"
+	CodeInvalidCursor      ErrorCode = "INVALID_CURSOR"
+	CodeUnsupportedFormat  ErrorCode = "UNSUPPORTED_FORMAT"
+	CodeImportTooLarge     ErrorCode = "IMPORT_TOO_LARGE"
+	CodeBatchTooLarge      ErrorCode = "BATCH_TOO_LARGE"
+	CodeBatchAborted       ErrorCode = "BATCH_ABORTED"
+	CodeUnauthorized       ErrorCode = "UNAUTHORIZED"
+	CodeInvalidCredentials ErrorCode = "INVALID_CREDENTIALS"
+	CodeTokenExpired       ErrorCode = "TOKEN_EXPIRED"
+	CodeInvalidAPIKey      ErrorCode = "INVALID_API_KEY"
+	CodeForbidden          ErrorCode = "FORBIDDEN"
+	CodeInsufficientScope  ErrorCode = "INSUFFICIENT_SCOPE"
+	CodeRateLimited        ErrorCode = "RATE_LIMITED"
+	CodeInternal           ErrorCode = "INTERNAL_ERROR"
+)
+
+var (
+	errInvalidQuery           = errors.New("invalid query parameters")
+	errMalformedRequest       = errors.New("malformed request body")
+	errValidationFailed       = errors.New("request validation failed")
+	errInternal               = errors.New("internal server error")
+	errConflict               = errors.New("conflict")
+	errPreconditionFailed     = errors.New("precondition failed")
+	errAuthenticationRequired = errors.New("authentication required")
+	errNotFound               = errors.New("not found")
+	errInvalidVersion         = errors.New("invalid version")
+	errUnprocessable          = errors.New("unprocessable request")
+	errInvalidIdempotencyKey  = errors.New("invalid idempotency key")
+	errUnsupportedFormat      = errors.New("unsupported format")
+	errImportTooLarge         = errors.New("too many rows to import")
+	errBatchTooLarge          = errors.New("too many operations in batch")
+	errBatchAborted           = errors.New("batch aborted by another operation")
+	errRateLimited            = errors.New("rate limit exceeded")
+)
+
+// errorCodes уточняет код ошибки API для конкретных причин;
+// для остальных используется код по типу ошибки сервиса
+var errorCodes = map[error]ErrorCode{
+	service.ErrNotFound:             CodeUserNotFound,
+	service.ErrAPIKeyNotFound:       CodeAPIKeyNotFound,
+	service.ErrVersionNotFound:      CodeVersionNotFound,
+	service.ErrEmailTaken:           CodeEmailTaken,
+	service.ErrEmailAlreadyVerified: CodeEmailVerified,
+	service.ErrNotDeleted:           CodeUserNotDeleted,
+	service.ErrExportNotFound:       CodeExportNotFound,
+	service.ErrExportNotReady:       CodeExportNotReady,
+
+	service.ErrIdempotencyKeyReused:     CodeIdempotencyKeyReused,
+	service.ErrIdempotencyKeyInProgress: CodeIdempotencyKeyInProgress,
+
+	service.ErrInvalidCredentials: CodeInvalidCredentials,
+	auth.ErrTokenExpired:          CodeTokenExpired,
+	auth.ErrInvalidAPIKey:         CodeInvalidAPIKey,
+	auth.ErrInsufficientScope:     CodeInsufficientScope,
+}
+
+func codeFor(err error, fallback ErrorCode) ErrorCode {
+	for cause, code := range errorCodes {
+		if errors.Is(err, cause) {
+			return code
+		}
+	}
+	return fallback
+}
+
+// FieldError описывает ошибку валидации отдельного поля запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// apiError — результат сопоставления ошибки с HTTP-ответом
+type apiError struct {
+	Status  int
+	Code    ErrorCode
+	Message string
+	Fields  []FieldError
+}
+
+// mapError — единственное место, где ошибки сопоставляются с HTTP-статусом
+// и кодом ошибки API: типизированные ошибки сервиса, ошибки валидатора
+// и ошибки разбора запроса. Сообщения берутся из каталога на языке переводчика t.
+func mapError(err error, t translator) apiError {
+	var (
+		notFound         *service.NotFoundError
+		validation       *service.ValidationError
+		conflict         *service.ConflictError
+		precondition     *service.PreconditionFailedError
+		unprocessable    *service.UnprocessableError
+		unauthorized     *service.UnauthorizedError
+		forbidden        *service.ForbiddenError
+		validationErrors validator.ValidationErrors
+		syntaxError      *json.SyntaxError
+		typeError        *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.As(err, &notFound):
+		return apiError{Status: http.StatusNotFound, Code: codeFor(err, CodeNotFound), Message: t.messageOr(err, errNotFound)}
+	case errors.As(err, &validation):
+		fields := make([]FieldError, 0, len(validation.Fields))
+		for _, v := range validation.Fields {
+			fields = append(fields, FieldError{Field: v.Field, Rule: v.Rule, Message: t.messageOr(v.Err, errValidationFailed)})
+		}
+		return apiError{
+			Status:  http.StatusBadRequest,
+			Code:    CodeValidationFailed,
+			Message: t.messageOr(err, errValidationFailed),
+			Fields:  fields,
+		}
+	case errors.As(err, &conflict):
+		return apiError{Status: http.StatusConflict, Code: codeFor(err, CodeConflict), Message: t.messageOr(err, errConflict)}
+	case errors.As(err, &precondition):
+		return apiError{Status: http.StatusPreconditionFailed, Code: CodePreconditionFailed, Message: t.messageOr(err, errPreconditionFailed)}
+	case errors.As(err, &unprocessable):
+		return apiError{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    codeFor(err, CodeUnprocessable),
+			Message: t.messageOr(err, errUnprocessable),
+		}
+	case errors.As(err, &unauthorized):
+		return apiError{
+			Status:  http.StatusUnauthorized,
+			Code:    codeFor(err, CodeUnauthorized),
+			Message: t.messageOr(err, errAuthenticationRequired),
+		}
+	case errors.As(err, &forbidden):
+		return apiError{Status: http.StatusForbidden, Code: codeFor(err, CodeForbidden), Message: t.messageOr(err, service.ErrForbidden)}
+	case errors.As(err, &validationErrors):
+		fields := make([]FieldError, 0, len(validationErrors))
+		for _, fe := range validationErrors {
+			fields = append(fields, FieldError{Field: fe.Field(), Rule: fe.Tag(), Message: t.field(fe)})
+		}
+		return apiError{
+			Status:  http.StatusBadRequest,
+			Code:    CodeValidationFailed,
+			Message: t.message(errValidationFailed),
+			Fields:  fields,
+		}
+	case errors.Is(err, service.ErrIDNotValid), errors.Is(err, service.ErrIDNotTransmitted):
+		return apiError{Status: http.StatusBadRequest, Code: CodeInvalidID, Message: t.message(err)}
+	case errors.Is(err, errInvalidIdempotencyKey):
+		return apiError{Status: http.StatusBadRequest, Code: CodeInvalidIdempotencyKey, Message: t.message(err)}
+	case errors.Is(err, errInvalidVersion):
+		return apiError{Status: http.StatusBadRequest, Code: CodeInvalidVersion, Message: t.message(err)}
+	case errors.Is(err, errUnsupportedFormat):
+		return apiError{Status: http.StatusUnsupportedMediaType, Code: CodeUnsupportedFormat, Message: t.message(err)}
+	case errors.Is(err, errImportTooLarge):
+		return apiError{Status: http.StatusRequestEntityTooLarge, Code: CodeImportTooLarge, Message: t.message(err)}
+	case errors.Is(err, errBatchTooLarge):
+		return apiError{Status: http.StatusRequestEntityTooLarge, Code: CodeBatchTooLarge, Message: t.message(err)}
+	case errors.Is(err, errBatchAborted):
+		return apiError{Status: http.StatusFailedDependency, Code: CodeBatchAborted, Message: t.message(err)}
+	case errors.Is(err, errRateLimited):
+		return apiError{Status: http.StatusTooManyRequests, Code: CodeRateLimited, Message: t.message(err)}
+	case errors.Is(err, repository.ErrInvalidCursor):
+		return apiError{Status: http.StatusBadRequest, Code: CodeInvalidCursor, Message: t.message(err)}
+	case errors.Is(err, errInvalidQuery),
+		errors.Is(err, repository.ErrInvalidSort),
+		errors.Is(err, repository.ErrCursorSort),
+		errors.Is(err, repository.ErrCursorOffset):
+		return apiError{Status: http.StatusBadRequest, Code: CodeInvalidQuery, Message: t.message(err)}
+	case errors.As(err, &syntaxError), errors.As(err, &typeError), errors.Is(err, errMalformedRequest):
+		return apiError{Status: http.StatusBadRequest, Code: CodeMalformedRequest, Message: t.message(errMalformedRequest)}
+	default:
+		return apiError{Status: http.StatusInternalServerError, Code: CodeInternal, Message: t.message(errInternal)}
+	}
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"api_server/internal/service"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestErrorType(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{service.ErrNotFound, "not_found"},
+		{&service.ConflictError{Err: service.ErrEmailTaken}, "conflict"},
+		{service.ErrVersionMismatch, "precondition_failed"},
+		{context.DeadlineExceeded, "timeout"},
+		{errors.New("connection refused"), "internal"},
+	}
+	for _, tt := range tests {
+		if got := service.ErrorType(tt.err); got != tt.want {
+			t.Errorf("ErrorType(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"api_server/internal/domain"
+	"api_server/internal/service"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCacheControl требует от клиента перепроверять ответ при каждом
+// обращении: с If-None-Match или If-Modified-Since это дешёвый 304
+const DefaultCacheControl = "private, no-cache"
+
+// userETag возвращает сильный ETag пользователя, построенный по его версии
+func userETag(user *domain.User) string {
+	return strconv.Quote(strconv.FormatUint(uint64(user.Version), 10))
+}
+
+func setUserETag(c *gin.Context, user *domain.User) {
+	c.Header("ETag", userETag(user))
+}
+
+// ifMatchVersion возвращает версию пользователя из заголовка If-Match.
+// Без заголовка или с "*" возвращается 0 — версия не проверяется.
+// Поддерживается один сильный ETag; слабый ETag или список по RFC 9110
+// не может совпасть при сильном сравнении с единственной версией, поэтому
+// такой запрос сразу получает 412.
+func ifMatchVersion(c *gin.Context) (uint, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	raw, err := strconv.Unquote(header)
+	if err != nil {
+		return 0, &service.PreconditionFailedError{Err: service.ErrVersionMismatch}
+	}
+	version, err := strconv.ParseUint(raw, 10, 0)
+	if err != nil || version == 0 {
+		return 0, &service.PreconditionFailedError{Err: service.ErrVersionMismatch}
+	}
+	return uint(version), nil
+}
+
+// usersETag возвращает слабый ETag страницы списка пользователей: он меняется,
+// если меняется состав страницы, версия любого пользователя на ней или пагинация
+func usersETag(users []domain.User, pagination Pagination) string {
+	h := sha256.New()
+	for _, u := range users {
+		h.Write([]byte(strconv.FormatUint(uint64(u.ID), 10) + ":" + strconv.FormatUint(uint64(u.Version), 10) + ";"))
+	}
+	if pagination.Total != nil {
+		h.Write([]byte("total:" + strconv.FormatInt(*pagination.Total, 10) + ";"))
+	}
+	h.Write([]byte(pagination.NextCursor + ";" + pagination.PrevCursor))
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// lastModified возвращает самое позднее время изменения среди пользователей
+func lastModified(users ...domain.User) time.Time {
+	var latest time.Time
+	for _, u := range users {
+		if u.UpdatedAt.After(latest) {
+			latest = u.UpdatedAt
+		}
+	}
+	return latest
+}
+
+// notModified выставляет валидаторы ответа и Cache-Control и, если валидаторы
+// клиента из If-None-Match или If-Modified-Since совпали, отвечает 304.
+// Возвращает true, если ответ уже записан.
+func (h *Handler) notModified(c *gin.Context, etag string, modified time.Time) bool {
+	c.Header("ETag", etag)
+	if !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	c.Header("Cache-Control", h.cacheControl())
+
+	// If-Modified-Since учитывается, только если нет If-None-Match (RFC 9110, 13.1.3)
+	if header := c.GetHeader("If-None-Match"); header != "" {
+		if !etagMatches(header, etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+		if err != nil || modified.IsZero() || modified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// etagMatches сравнивает ETag со списком из If-None-Match слабым сравнением
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"api_server/internal/domain"
+	"api_server/internal/export"
+	"api_server/internal/repository"
+	"api_server/internal/service"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type ExportUsersRequest struct {
+	Format  string `form:"format" validate:"omitempty,oneof=csv ndjson vcf"`
+	Columns string `form:"columns"`
+
+	columns []string
+}
+
+// ExportUsers godoc
+// @Summary      Выгрузка пользователей в CSV, NDJSON или vCard
+// @Description  Пользователи выбираются из базы пачками и передаются клиенту по мере выборки.
+// @Description  Фильтры и сортировка — как в GET /users, но учитывается только одно поле сортировки,
+// @Description  а limit и offset не поддерживаются. Ошибка посреди выгрузки обрывает соединение.
+// @Description  Ячейки CSV, начинающиеся с "=", "+", "-" или "@", экранируются апострофом.
+// @Tags         users
+// @Produce      text/csv,application/x-ndjson,text/vcard
+// @Param        format           query  string  false  "Формат выгрузки"  Enums(csv, ndjson, vcf)  default(csv)
+// @Param        columns          query  string  false  "Столбцы CSV через запятую: id, name, email, age, role, version, created_at, updated_at, deleted_at"
+// @Param        include_deleted  query  bool    false  "Включить удалённых пользователей"
+// @Param        only_deleted     query  bool    false  "Только удалённые пользователи"
+// @Param        sort             query  string  false  "Сортировка, например -created_at"
+// @Param        name             query  string  false  "Подстрока имени"
+// @Param        email            query  string  false  "Email"
+// @Param        min_age          query  int     false  "Минимальный возраст"
+// @Param        max_age          query  int     false  "Максимальный возраст"
+// @Param        created_from     query  string  false  "Создан не раньше (RFC 3339)"
+// @Param        created_to       query  string  false  "Создан не позже (RFC 3339)"
+// @Success      200  {file}    file
+// @Header       200  {string}  Content-Disposition  "attachment; filename=users-<время>.<формат>"
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      500  {object}  Response
+// @Security     BearerAuth
+// @Router       /users/export [get]
+func (h *Handler) ExportUsers(c *gin.Context) {
+	request, query, err := h.config.parseExportRequest(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// Заголовки пишутся с первой пачкой: до неё об ошибке ещё можно сообщить обычным ответом
+	var encoder export.Encoder
+	start := func() error {
+		if encoder != nil {
+			return nil
+		}
+		c.Header("Content-Type", export.ContentType(request.Format))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.Filename(request.Format, time.Now())))
+		c.Status(http.StatusOK)
+		e, err := export.NewEncoder(request.Format, c.Writer, request.columns)
+		encoder = e
+		return err
+	}
+
+	err = h.userService.ExportUsers(c.Request.Context(), query, service.ExportBatchSize, func(users []domain.User) error {
+		if err := start(); err != nil {
+			return err
+		}
+		if err := encoder.Encode(users); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err == nil {
+		err = start()
+	}
+	if err == nil {
+		err = encoder.Close()
+	}
+	if err != nil && encoder != nil {
+		// Ответ уже начат: без обрыва соединения клиент получил бы усечённый файл как целый
+		slog.ErrorContext(c.Request.Context(), "export aborted", "error", err)
+		panic(http.ErrAbortHandler)
+	}
+	if err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// parseExportRequest разбирает формат, столбцы и фильтры выгрузки
+func (config Config) parseExportRequest(c *gin.Context) (ExportUsersRequest, repository.UserQuery, error) {
+	var request ExportUsersRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		return request, repository.UserQuery{}, fmt.Errorf("%w: %v", errInvalidQuery, err)
+	}
+	if err := validate.Struct(request); err != nil {
+		return request, repository.UserQuery{}, err
+	}
+	if request.Format == "" {
+		request.Format = export.FormatCSV
+	}
+	columns, err := export.ParseColumns(request.Columns)
+	if err != nil {
+		return request, repository.UserQuery{}, fmt.Errorf("%w: %v", errInvalidQuery, err)
+	}
+	request.columns = columns
+
+	query, err := config.parseUserQuery(c)
+	if err != nil {
+		return request, repository.UserQuery{}, err
+	}
+	if query.Offset != 0 {
+		return request, repository.UserQuery{}, repository.ErrCursorOffset
+	}
+	if len(query.Sort) > 1 {
+		return request, repository.UserQuery{}, repository.ErrCursorSort
+	}
+	return request, query, nil
+}
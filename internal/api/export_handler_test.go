@@ -0,0 +1,177 @@
+package api
+
+import (
+	"api_server/internal/domain"
+	"api_server/internal/logging"
+	"api_server/internal/metrics"
+	"api_server/internal/repository"
+	"api_server/internal/repository/memory"
+	"api_server/internal/service"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func exportUsers(t *testing.T, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := newTestRouter()
+	r.GET("/users/export", handler.ExportUsers)
+
+	req, err := http.NewRequest(http.MethodGet, "/users/export"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandler_ExportUsersCSV(t *testing.T) {
+	_, total, err := svc.GetUsers(t.Context(), repository.UserQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := exportUsers(t, "?columns=id,email&sort=-id")
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, `attachment; filename="users-`) || !strings.HasSuffix(cd, `.csv"`) {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(records[0], ",") != "id,email" {
+		t.Errorf("header = %v, want [id email]", records[0])
+	}
+	if int64(len(records)-1) != total {
+		t.Fatalf("exported %d users, want %d", len(records)-1, total)
+	}
+	first, _ := strconv.Atoi(records[1][0])
+	last, _ := strconv.Atoi(records[len(records)-1][0])
+	if first < last {
+		t.Errorf("users are not sorted by id desc: first %d, last %d", first, last)
+	}
+}
+
+func TestHandler_ExportUsersCSVEscapesFormulas(t *testing.T) {
+	createTestUser(t, "=SUM(A1)", "formula@example.com")
+
+	w := exportUsers(t, "?columns=name&email=formula@example.com")
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1][0] != "'=SUM(A1)" {
+		t.Errorf("records = %q, want name escaped as '=SUM(A1)", records)
+	}
+}
+
+// brokenCursorRepository отдаёт первую пачку выгрузки и обещает следующую,
+// но следующая выборка падает, как при обрыве соединения с базой
+type brokenCursorRepository struct {
+	repository.UserRepositoryInterface
+}
+
+func (r brokenCursorRepository) GetAllByCursor(ctx context.Context, query repository.UserQuery, cursor *repository.Cursor) ([]domain.User, bool, error) {
+	if cursor != nil {
+		return nil, false, errors.New("connection reset")
+	}
+	users, _, err := r.UserRepositoryInterface.GetAllByCursor(ctx, query, cursor)
+	return users, true, err
+}
+
+func TestHandler_ExportUsersAbortedMidStream(t *testing.T) {
+	m := metrics.New()
+	s := service.NewUserService(brokenCursorRepository{repo}, memory.NewMockMemoryUserHistoryRepository(), policy, audit, memory.NewMockMemoryTransactor(), nil, nil, nil)
+	var buf bytes.Buffer
+
+	r := gin.New()
+	r.Use(Logger(logging.New(&buf, slog.LevelInfo)), Metrics(m), Recovery(), ErrorHandler())
+	r.GET("/users/export", (&Handler{userService: s}).ExportUsers)
+	r.GET("/metrics", gin.WrapH(m.Handler()))
+
+	func() {
+		defer func() {
+			if err := recover(); err != http.ErrAbortHandler {
+				t.Errorf("recovered %v, want http.ErrAbortHandler", err)
+			}
+		}()
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/export", nil))
+	}()
+
+	if !strings.Contains(buf.String(), `"aborted":true`) {
+		t.Errorf("aborted export is not in the access log: %s", buf.String())
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		`api_http_requests_total{method="GET",route="/users/export",status="200"} 1`,
+		// Оборванная выгрузка завершена: в работе только сам запрос к /metrics
+		`api_http_requests_in_flight 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics have no %q", want)
+		}
+	}
+}
+
+func TestHandler_ExportUsersNDJSON(t *testing.T) {
+	w := exportUsers(t, "?format=ndjson&email=test_2@example.com")
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+
+	var users []domain.User
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var u domain.User
+		if err := json.Unmarshal(scanner.Bytes(), &u); err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, u)
+	}
+	if len(users) != 1 || users[0].Email != "test_2@example.com" {
+		t.Errorf("unexpected export: %+v", users)
+	}
+}
+
+func TestHandler_ExportUsersVCard(t *testing.T) {
+	w := exportUsers(t, "?format=vcf&email=test_3@example.com")
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+	body := w.Body.String()
+	if !strings.HasPrefix(body, "BEGIN:VCARD\r\nVERSION:4.0\r\n") || !strings.Contains(body, "EMAIL:test_3@example.com\r\n") {
+		t.Errorf("unexpected vCard: %q", body)
+	}
+}
+
+func TestHandler_ExportUsersInvalidQuery(t *testing.T) {
+	for _, query := range []string{"?columns=id,password_hash", "?format=xml", "?offset=10", "?sort=name,id"} {
+		if w := exportUsers(t, query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"api_server/internal/export"
+	"api_server/internal/service"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type ExportJobHandler struct {
+	exportService *service.ExportService
+	config        Config
+}
+
+func NewExportJobHandler(s *service.ExportService, config Config) *ExportJobHandler {
+	return &ExportJobHandler{exportService: s, config: config}
+}
+
+// StartExport godoc
+// @Summary      Запуск фоновой выгрузки пользователей
+// @Description  Параметры — как в GET /users/export. Состояние задания доступно по адресу из заголовка Location,
+// @Description  готовый файл — по GET /exports/{id}/file. Задание видно только запустившему его.
+// @Tags         exports
+// @Produce      json
+// @Param        format           query  string  false  "Формат выгрузки"  Enums(csv, ndjson, vcf)  default(csv)
+// @Param        columns          query  string  false  "Столбцы CSV через запятую"
+// @Param        include_deleted  query  bool    false  "Включить удалённых пользователей"
+// @Param        only_deleted     query  bool    false  "Только удалённые пользователи"
+// @Param        sort             query  string  false  "Сортировка, например -created_at"
+// @Param        name             query  string  false  "Подстрока имени"
+// @Param        email            query  string  false  "Email"
+// @Param        min_age          query  int     false  "Минимальный возраст"
+// @Param        max_age          query  int     false  "Максимальный возраст"
+// @Param        created_from     query  string  false  "Создан не раньше (RFC 3339)"
+// @Param        created_to       query  string  false  "Создан не позже (RFC 3339)"
+// @Success      202  {object}  Response{data=domain.ExportJob}
+// @Header       202  {string}  Location  "Адрес задания"
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      500  {object}  Response
+// @Security     BearerAuth
+// @Router       /exports [post]
+func (h *ExportJobHandler) StartExport(c *gin.Context) {
+	request, query, err := h.config.parseExportRequest(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	job, err := h.exportService.StartExport(c.Request.Context(), request.Format, request.columns, query)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.Header("Location", fmt.Sprintf("/exports/%d", job.ID))
+	writeSuccessResponse(c, http.StatusAccepted, job)
+}
+
+// GetExport godoc
+// @Summary      Состояние фоновой выгрузки
+// @Description  processed и total показывают прогресс выгрузки.
+// @Tags         exports
+// @Produce      json
+// @Param        id   path      int  true  "ID задания"
+// @Success      200  {object}  Response{data=domain.ExportJob}
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      404  {object}  Response
+// @Failure      500  {object}  Response
+// @Security     BearerAuth
+// @Router       /exports/{id} [get]
+func (h *ExportJobHandler) GetExport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		_ = c.Error(service.ErrIDNotValid)
+		return
+	}
+
+	job, err := h.exportService.GetExport(c.Request.Context(), uint(id))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	writeSuccessResponse(c, http.StatusOK, job)
+}
+
+// GetExportFile godoc
+// @Summary      Скачивание файла фоновой выгрузки
+// @Description  Пока задание не завершено, возвращается 409.
+// @Tags         exports
+// @Produce      text/csv,application/x-ndjson,text/vcard
+// @Param        id   path      int  true  "ID задания"
+// @Success      200  {file}    file
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      404  {object}  Response
+// @Failure      409  {object}  Response
+// @Failure      500  {object}  Response
+// @Security     BearerAuth
+// @Router       /exports/{id}/file [get]
+func (h *ExportJobHandler) GetExportFile(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		_ = c.Error(service.ErrIDNotValid)
+		return
+	}
+
+	job, err := h.exportService.ExportFile(c.Request.Context(), uint(id))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.Header("Content-Type", export.ContentType(job.Format))
+	c.FileAttachment(job.FilePath, export.Filename(job.Format, job.CreatedAt))
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/domain"
+	"api_server/internal/repository/memory"
+	"api_server/internal/service"
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportJobHandler_Export(t *testing.T) {
+	exports := service.NewExportService(memory.NewMockMemoryExportJobRepository(), svc, policy, t.TempDir(), time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		exports.Run(ctx, time.Hour)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	h := NewExportJobHandler(exports, Config{})
+	r := newTestRouter()
+	r.POST("/exports", h.StartExport)
+	r.GET("/exports/:id", h.GetExport)
+	r.GET("/exports/:id/file", h.GetExportFile)
+	serve := func(method, url string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodPost, "/exports?format=csv&columns=email&email=test_2@example.com")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", w.Code, http.StatusAccepted, w.Body.String())
+	}
+	var job domain.ExportJob
+	if err := decodeData(w.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	location := w.Header().Get("Location")
+	if location != fmt.Sprintf("/exports/%d", job.ID) {
+		t.Errorf("Location = %q", location)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status != domain.ExportCompleted {
+		if job.Status == domain.ExportFailed || time.Now().After(deadline) {
+			t.Fatalf("export was not completed: %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+		w = serve(http.MethodGet, location)
+		if w.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+		}
+		if err := decodeData(w.Body.Bytes(), &job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if job.Total != 1 || job.Processed != 1 || job.ExpiresAt == nil {
+		t.Errorf("unexpected completed job: %+v", job)
+	}
+
+	w = serve(http.MethodGet, location+"/file")
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+	if body := w.Body.String(); body != "email\ntest_2@example.com\n" {
+		t.Errorf("unexpected export file: %q", body)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, ".csv") {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+
+	// Задание другого субъекта не видно
+	other := newTestRouter()
+	other.Use(func(c *gin.Context) {
+		setPrincipal(c, auth.Principal{APIKeyID: 1})
+	})
+	other.GET("/exports/:id", h.GetExport)
+	req, err := http.NewRequest(http.MethodGet, location, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	other.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusNotFound)
+	}
+}
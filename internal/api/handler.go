@@ -1,39 +1,71 @@
 package api
 
 import (
+	"api_server/internal/domain"
+	"api_server/internal/repository"
 	"api_server/internal/service"
-	"errors"
+	"context"
+	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator/v10"
 	"net/http"
 	"strconv"
+	"time"
 )
 
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+type Config struct {
+	// DefaultPageSize — размер страницы списка пользователей, если limit не передан
+	DefaultPageSize int
+	// MaxPageSize — максимально допустимое значение limit
+	MaxPageSize int
+	// CacheControl — значение заголовка Cache-Control для GET /user/{id} и GET /users;
+	// по умолчанию DefaultCacheControl
+	CacheControl string
+	// MaxImportRows — максимальное число строк в файле импорта; по умолчанию MaxImportRows
+	MaxImportRows int
+	// MaxBatchSize — максимальное число операций в POST /users/batch; по умолчанию MaxBatchSize
+	MaxBatchSize int
+}
+
 type Handler struct {
 	userService *service.UserService
+	config      Config
 }
 
 type UpdateUserRequest struct {
-	Name string `json:"name"`
-	Age  uint   `json:"age" validate:"min=14"`
+	Name string      `json:"name"`
+	Age  uint        `json:"age" validate:"omitempty,min=14"`
+	Role domain.Role `json:"role" validate:"omitempty,oneof=admin manager member"`
 }
 
 type CreateUserRequest struct {
-	Name  string `json:"name" validate:"required"`
-	Age   uint   `json:"age" validate:"required,min=14"`
-	Email string `json:"email" validate:"required,email"`
+	Name     string      `json:"name" validate:"required"`
+	Age      uint        `json:"age" validate:"required,min=14"`
+	Email    string      `json:"email" validate:"required,email"`
+	Password string      `json:"password" validate:"required,min=8,max=72"`
+	Role     domain.Role `json:"role" validate:"omitempty,oneof=admin manager member"`
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
+type ListUsersRequest struct {
+	IncludeDeleted bool       `form:"include_deleted"`
+	OnlyDeleted    bool       `form:"only_deleted"`
+	Limit          int        `form:"limit" validate:"omitempty,min=1"`
+	Offset         int        `form:"offset" validate:"omitempty,min=0"`
+	Sort           string     `form:"sort"`
+	Name           string     `form:"name"`
+	Email          string     `form:"email" validate:"omitempty,email"`
+	MinAge         *uint      `form:"min_age"`
+	MaxAge         *uint      `form:"max_age"`
+	CreatedFrom    *time.Time `form:"created_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedTo      *time.Time `form:"created_to" time_format:"2006-01-02T15:04:05Z07:00"`
 }
 
-type ErrorUserResponse struct {
-	Message string `json:"message"`
-}
-
-func NewHandler(s *service.UserService) *Handler {
-	return &Handler{userService: s}
+func NewHandler(s *service.UserService, config Config) *Handler {
+	return &Handler{userService: s, config: config}
 }
 
 // Ping godoc
@@ -51,25 +83,35 @@ func (h *Handler) Ping(c *gin.Context) {
 // @Tags         user
 // @Produce      json
 // @Param        id   query      int  true "ID пользователя"
-// @Success      200  {object}  domain.User
-// @Failure      400  {object}  ErrorResponse
-// @Failure      500  {object}  ErrorResponse
-// @Failure      404  {object}  ErrorResponse
+// @Success      200  {object}  Response{data=domain.User}
+// @Param        If-None-Match      header  string  false  "ETag из предыдущего ответа"
+// @Param        If-Modified-Since  header  string  false  "Last-Modified из предыдущего ответа"
+// @Success      304  "Пользователь не изменился"
+// @Header       200  {string}  ETag  "Версия пользователя для If-Match и If-None-Match"
+// @Header       200  {string}  Last-Modified  "Время последнего изменения пользователя"
+// @Failure      400  {object}  Response
+// @Failure      500  {object}  Response
+// @Failure      404  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Security     BearerAuth
 // @Router       /user/{id} [get]
 func (h *Handler) GetUser(c *gin.Context) {
 	id, err := h.ParseUserId(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(service.ErrIDNotValid)
 		return
 	}
 
-	user, err := h.userService.GetUserByID(id)
-	if err != nil && errors.Is(err, service.ErrNotFound) {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	user, err := h.userService.GetUserByID(c.Request.Context(), id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if h.notModified(c, userETag(user), user.UpdatedAt) {
 		return
 	}
-	c.JSON(http.StatusOK, user)
-	return
+	writeSuccessResponse(c, http.StatusOK, user)
 }
 
 // CreateUser godoc
@@ -77,122 +119,497 @@ func (h *Handler) GetUser(c *gin.Context) {
 // @Tags         user
 // @Accept       json
 // @Produce      json
-// @Param        request   body      CreateUserRequest  true  "JSON"
-// @Success      201       {object}  domain.User
-// @Failure      400       {object}  ErrorResponse
-// @Failure      500       {object}  ErrorResponse
+// @Param        request          body      CreateUserRequest  true   "JSON"
+// @Param        Idempotency-Key  header    string             false  "Ключ для безопасного повтора запроса"
+// @Success      201       {object}  Response{data=domain.User}
+// @Failure      400       {object}  Response
+// @Failure      409       {object}  Response
+// @Failure      422       {object}  Response
+// @Failure      429       {object}  Response
+// @Failure      500       {object}  Response
+// @Failure      401       {object}  Response
+// @Failure      403       {object}  Response
+// @Security     BearerAuth
 // @Router       /user [post]
 func (h *Handler) CreateUser(c *gin.Context) {
 	var request CreateUserRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-	}
-	validate := validator.New()
-	if err := validate.Struct(request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(fmt.Errorf("%w: %v", errMalformedRequest, err))
 		return
 	}
-	u, err := h.userService.CreateUser(request.Name, request.Email, request.Age)
+	u, err := h.createUser(c.Request.Context(), request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
+		return
+	}
+	setUserETag(c, u)
+	writeSuccessResponse(c, http.StatusCreated, u)
+}
+
+// createUser, updateUser и deleteUser выполняют запросы к одному пользователю;
+// их используют и одиночные обработчики, и пакетные операции (см. BatchUsers)
+func (h *Handler) createUser(ctx context.Context, request CreateUserRequest) (*domain.User, error) {
+	if err := validate.Struct(request); err != nil {
+		return nil, err
 	}
-	c.JSON(http.StatusCreated, u)
+	return h.userService.CreateUser(ctx, request.Name, request.Email, request.Age, request.Password, request.Role)
 }
 
 // UpdateUser godoc
 // @Summary Обновление пользователя
+// @Description  Участник может изменять только свою запись; роль может изменить только администратор, API-ключи роли не меняют.
+// @Description  С заголовком If-Match пользователь изменяется, только если его ETag не изменился, иначе возвращается 412.
 // @Tags         user
 // @Accept       json
 // @Produce      json
 // @Param        id        query     int  true "ID пользователя"
+// @Param        If-Match  header    string  false  "ETag пользователя из GET /user/{id}"
 // @Param        request   body      UpdateUserRequest  true  "JSON"
-// @Success      200       {object}  domain.User
-// @Failure      400       {object}  ErrorResponse
-// @Failure      500       {object}  ErrorResponse
-// @Failure      404       {object}  ErrorResponse
+// @Success      200       {object}  Response{data=domain.User}
+// @Header       200       {string}  ETag  "Новая версия пользователя"
+// @Failure      400       {object}  Response
+// @Failure      500       {object}  Response
+// @Failure      404       {object}  Response
+// @Failure      401       {object}  Response
+// @Failure      403       {object}  Response
+// @Failure      412       {object}  Response
+// @Security     BearerAuth
 // @Router       /user/{id} [patch]
 func (h *Handler) UpdateUser(c *gin.Context) {
 	id, err := h.ParseUserId(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(service.ErrIDNotValid)
 		return
 	}
 
 	var request UpdateUserRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(fmt.Errorf("%w: %v", errMalformedRequest, err))
 		return
 	}
-
-	user, err := h.userService.GetUserByID(id)
-	if err != nil && errors.Is(err, service.ErrNotFound) {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	userName := user.Name
-	if request.Name != userName && request.Name != "" {
-		userName = request.Name
+	updatedUser, err := h.updateUser(c.Request.Context(), id, version, request)
+	if err != nil {
+		_ = c.Error(err)
+		return
 	}
 
-	userAge := user.Age
-	if request.Age != userAge && request.Age > 14 {
-		userAge = request.Age
+	setUserETag(c, updatedUser)
+	writeSuccessResponse(c, http.StatusOK, updatedUser)
+}
+
+// updateUser изменяет пользователя id; при version != 0 — только если его версия не изменилась
+func (h *Handler) updateUser(ctx context.Context, id, version uint, request UpdateUserRequest) (*domain.User, error) {
+	if err := validate.Struct(request); err != nil {
+		return nil, err
 	}
+	var user *domain.User
+	// Роль и остальные поля меняются вместе: если UpdateUser не пройдёт,
+	// смена роли тоже откатится
+	err := h.userService.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = h.userService.GetUserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if version != 0 && version != user.Version {
+			return &service.PreconditionFailedError{Err: service.ErrVersionMismatch}
+		}
+
+		// Изменения применяются к прочитанной версии: если пользователя успели
+		// изменить другим запросом, запись не пройдёт и вернётся 412
+		if request.Role != "" && request.Role != user.Role {
+			user, err = h.userService.ChangeRole(ctx, user.ID, request.Role, user.Version)
+			if err != nil {
+				return err
+			}
+		}
+
+		userName := user.Name
+		if request.Name != userName && request.Name != "" {
+			userName = request.Name
+		}
 
-	updatedUser, err := h.userService.UpdateUser(user.Model.ID, userName, userAge)
+		userAge := user.Age
+		if request.Age != 0 {
+			userAge = request.Age
+		}
+
+		user, err = h.userService.UpdateUser(ctx, user.Model.ID, userName, userAge, user.Version)
+		return err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
-
-	c.JSON(http.StatusOK, updatedUser)
+	return user, nil
 }
 
 // DeleteUser godoc
 // @Summary Удаление пользователя
+// @Description  Доступно только администраторам. По умолчанию пользователь удаляется мягко и его можно
+// @Description  восстановить через POST /user/{id}/restore; с hard=true он удаляется безвозвратно.
+// @Description  С заголовком If-Match пользователь удаляется, только если его ETag не изменился, иначе возвращается 412.
 // @Tags         user
 // @Accept       json
 // @Produce      json
 // @Param        id        query     int  true "ID пользователя"
-// @Success      200
-// @Failure      400       {object}  ErrorResponse
-// @Failure      500       {object}  ErrorResponse
-// @Failure      404       {object}  ErrorResponse
+// @Param        hard      query     bool false "Удалить безвозвратно"
+// @Param        If-Match  header    string  false  "ETag пользователя из GET /user/{id}"
+// @Success      200       {object}  Response
+// @Failure      400       {object}  Response
+// @Failure      500       {object}  Response
+// @Failure      404       {object}  Response
+// @Failure      401       {object}  Response
+// @Failure      403       {object}  Response
+// @Failure      412       {object}  Response
+// @Security     BearerAuth
 // @Router       /user/{id} [delete]
 func (h *Handler) DeleteUser(c *gin.Context) {
 	id, err := h.ParseUserId(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(service.ErrIDNotValid)
+		return
+	}
+	hard := false
+	if v, ok := c.GetQuery("hard"); ok {
+		hard, err = strconv.ParseBool(v)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("%w: hard: %v", errInvalidQuery, err))
+			return
+		}
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := h.deleteUser(c.Request.Context(), id, version, hard); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	writeSuccessResponse(c, http.StatusOK, nil)
+}
+
+// deleteUser удаляет пользователя мягко или, при hard, безвозвратно
+func (h *Handler) deleteUser(ctx context.Context, id, version uint, hard bool) error {
+	if hard {
+		return h.userService.PurgeUser(ctx, id, version)
+	}
+	return h.userService.DeleteUser(ctx, id, version)
+}
+
+// RestoreUser godoc
+// @Summary      Восстановление удалённого пользователя
+// @Description  Доступно менеджерам и администраторам, пока пользователь не удалён безвозвратно.
+// @Tags         user
+// @Produce      json
+// @Param        id   path      int  true "ID пользователя"
+// @Success      200  {object}  Response{data=domain.User}
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      404  {object}  Response
+// @Failure      409  {object}  Response
+// @Failure      500  {object}  Response
+// @Security     BearerAuth
+// @Router       /user/{id}/restore [post]
+func (h *Handler) RestoreUser(c *gin.Context) {
+	id, err := h.ParseUserId(c.Param("id"))
+	if err != nil {
+		_ = c.Error(service.ErrIDNotValid)
 		return
 	}
-	_, err = h.userService.GetUserByID(id)
-	if err != nil && errors.Is(err, service.ErrNotFound) {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+	user, err := h.userService.RestoreUser(c.Request.Context(), id)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
-	err = h.userService.DeleteUser(id)
+	setUserETag(c, user)
+	writeSuccessResponse(c, http.StatusOK, user)
+}
+
+// GetUserHistory godoc
+// @Summary      История изменений пользователя
+// @Description  Каждое обновление сохраняет прежнее состояние пользователя очередной версией.
+// @Description  Версии возвращаются от новых к старым; текущее состояние в историю не входит.
+// @Tags         user
+// @Produce      json
+// @Param        id   path      int  true "ID пользователя"
+// @Success      200  {object}  Response{data=[]domain.UserVersion}
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      404  {object}  Response
+// @Failure      500  {object}  Response
+// @Security     BearerAuth
+// @Router       /user/{id}/history [get]
+func (h *Handler) GetUserHistory(c *gin.Context) {
+	id, err := h.ParseUserId(c.Param("id"))
+	if err != nil {
+		_ = c.Error(service.ErrIDNotValid)
+		return
+	}
+
+	versions, err := h.userService.GetHistory(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
-	c.Status(http.StatusOK)
+	writeSuccessResponse(c, http.StatusOK, versions)
+}
+
+// GetUserVersion godoc
+// @Summary      Версия пользователя
+// @Tags         user
+// @Produce      json
+// @Param        id       path      int  true "ID пользователя"
+// @Param        version  path      int  true "Номер версии"
+// @Success      200  {object}  Response{data=domain.UserVersion}
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      404  {object}  Response
+// @Failure      500  {object}  Response
+// @Security     BearerAuth
+// @Router       /user/{id}/history/{version} [get]
+func (h *Handler) GetUserVersion(c *gin.Context) {
+	id, version, err := h.parseVersionParams(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	v, err := h.userService.GetVersion(c.Request.Context(), id, version)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	writeSuccessResponse(c, http.StatusOK, v)
+}
+
+// RevertUser godoc
+// @Summary      Откат пользователя к версии
+// @Description  Возвращает имя и возраст из указанной версии; текущее состояние сохраняется новой версией.
+// @Tags         user
+// @Produce      json
+// @Param        id       path      int  true "ID пользователя"
+// @Param        version  path      int  true "Номер версии"
+// @Success      200  {object}  Response{data=domain.User}
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      404  {object}  Response
+// @Failure      500  {object}  Response
+// @Security     BearerAuth
+// @Router       /user/{id}/revert/{version} [post]
+func (h *Handler) RevertUser(c *gin.Context) {
+	id, version, err := h.parseVersionParams(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	user, err := h.userService.RevertUser(c.Request.Context(), id, version)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	setUserETag(c, user)
+	writeSuccessResponse(c, http.StatusOK, user)
+}
+
+func (h *Handler) parseVersionParams(c *gin.Context) (id, version uint, err error) {
+	id, err = h.ParseUserId(c.Param("id"))
+	if err != nil {
+		return 0, 0, service.ErrIDNotValid
+	}
+	v, err := strconv.ParseUint(c.Param("version"), 10, 0)
+	if err != nil || v == 0 {
+		return 0, 0, errInvalidVersion
+	}
+	return id, uint(v), nil
 }
 
 // GetUsers godoc
 // @Summary      Получение списка пользователей
+// @Description  Доступно менеджерам и администраторам.
+// @Description  Общее количество пользователей, подходящих под фильтры, возвращается в meta.pagination.total
+// @Description  и в заголовке X-Total-Count. При передаче cursor используется выборка по ключу: total не считается,
+// @Description  а курсоры соседних страниц возвращаются в meta.pagination и в заголовках X-Next-Cursor и X-Prev-Cursor.
 // @Tags         users
 // @Produce      json
-// @Success      200  {object}  []domain.User
+// @Param        include_deleted  query  bool    false  "Включить удалённых пользователей"
+// @Param        only_deleted  query     bool    false  "Только удалённые пользователи"
+// @Param        limit         query     int     false  "Размер страницы"
+// @Param        offset        query     int     false  "Смещение"
+// @Param        cursor        query     string  false  "Курсор для постраничной выборки по ключу; пустое значение — первая страница"
+// @Param        sort          query     string  false  "Сортировка, например -created_at,name"
+// @Param        name          query     string  false  "Подстрока имени"
+// @Param        email         query     string  false  "Email"
+// @Param        min_age       query     int     false  "Минимальный возраст"
+// @Param        max_age       query     int     false  "Максимальный возраст"
+// @Param        created_from  query     string  false  "Создан не раньше (RFC 3339)"
+// @Param        created_to    query     string  false  "Создан не позже (RFC 3339)"
+// @Success      200  {object}  Response{data=[]domain.User}
+// @Header       200  {int}     X-Total-Count  "Общее количество пользователей"
+// @Header       200  {string}  X-Next-Cursor  "Курсор следующей страницы"
+// @Header       200  {string}  X-Prev-Cursor  "Курсор предыдущей страницы"
+// @Header       200  {string}  ETag           "Слабый ETag страницы для If-None-Match"
+// @Header       200  {string}  Last-Modified  "Самое позднее изменение пользователей на странице"
+// @Success      304  "Страница не изменилась"
+// @Failure      400  {object}  Response
+// @Failure      500  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Security     BearerAuth
 // @Router       /users [get]
 func (h *Handler) GetUsers(c *gin.Context) {
-	users, err := h.userService.GetUsers()
+	query, err := h.config.parseUserQuery(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		h.getUsersByCursor(c, query, cursor)
+		return
+	}
+
+	users, total, err := h.userService.GetUsers(c.Request.Context(), query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
+		return
+	}
+
+	pagination := Pagination{
+		Limit:  query.Limit,
+		Offset: query.Offset,
+		Total:  &total,
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	if h.notModified(c, usersETag(users, pagination), lastModified(users...)) {
 		return
 	}
+	writeListResponse(c, http.StatusOK, users, pagination)
+}
 
-	c.JSON(http.StatusOK, users)
+func (h *Handler) getUsersByCursor(c *gin.Context, query repository.UserQuery, rawCursor string) {
+	if query.Offset != 0 {
+		_ = c.Error(repository.ErrCursorOffset)
+		return
+	}
+	if len(query.Sort) > 1 {
+		_ = c.Error(repository.ErrCursorSort)
+		return
+	}
+
+	sort := repository.SortField{Column: "id"}
+	if len(query.Sort) > 0 {
+		sort = query.Sort[0]
+	}
+
+	var cursor *repository.Cursor
+	if rawCursor != "" {
+		var err error
+		cursor, err = repository.DecodeCursor(rawCursor)
+		if err != nil || (len(query.Sort) > 0 && cursor.Sort != sort) {
+			_ = c.Error(repository.ErrInvalidCursor)
+			return
+		}
+		sort = cursor.Sort
+	}
+
+	users, hasMore, err := h.userService.GetUsersByCursor(c.Request.Context(), query, cursor)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	pagination := Pagination{Limit: query.Limit}
+	if len(users) > 0 {
+		backward := cursor != nil && cursor.Backward
+		if hasMore || backward {
+			pagination.NextCursor = repository.NewCursor(users[len(users)-1], sort, false).Encode()
+			c.Header("X-Next-Cursor", pagination.NextCursor)
+		}
+		if (hasMore && backward) || (cursor != nil && !backward) {
+			pagination.PrevCursor = repository.NewCursor(users[0], sort, true).Encode()
+			c.Header("X-Prev-Cursor", pagination.PrevCursor)
+		}
+	}
+	if h.notModified(c, usersETag(users, pagination), lastModified(users...)) {
+		return
+	}
+	writeListResponse(c, http.StatusOK, users, pagination)
+}
+
+func (config Config) parseUserQuery(c *gin.Context) (repository.UserQuery, error) {
+	var request ListUsersRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		return repository.UserQuery{}, fmt.Errorf("%w: %v", errInvalidQuery, err)
+	}
+	if err := validate.Struct(request); err != nil {
+		return repository.UserQuery{}, err
+	}
+
+	sort, err := repository.ParseUserSort(request.Sort)
+	if err != nil {
+		return repository.UserQuery{}, err
+	}
+
+	deleted := repository.ExcludeDeleted
+	switch {
+	case request.OnlyDeleted:
+		deleted = repository.OnlyDeleted
+	case request.IncludeDeleted:
+		deleted = repository.IncludeDeleted
+	}
+
+	return repository.UserQuery{
+		Filter: repository.UserFilter{
+			Deleted:     deleted,
+			Name:        request.Name,
+			Email:       request.Email,
+			MinAge:      request.MinAge,
+			MaxAge:      request.MaxAge,
+			CreatedFrom: request.CreatedFrom,
+			CreatedTo:   request.CreatedTo,
+		},
+		Sort:   sort,
+		Limit:  config.limit(request.Limit),
+		Offset: request.Offset,
+	}, nil
+}
+
+// limit возвращает размер страницы для запрошенного limit: размер по умолчанию,
+// если limit не передан, и не больше максимального
+func (c Config) limit(limit int) int {
+	if limit == 0 {
+		limit = DefaultPageSize
+		if c.DefaultPageSize > 0 {
+			limit = c.DefaultPageSize
+		}
+	}
+	maxPageSize := MaxPageSize
+	if c.MaxPageSize > 0 {
+		maxPageSize = c.MaxPageSize
+	}
+	return min(limit, maxPageSize)
+}
+
+func (h *Handler) cacheControl() string {
+	if h.config.CacheControl != "" {
+		return h.config.CacheControl
+	}
+	return DefaultCacheControl
 }
 
 func (h *Handler) ParseUserId(idStr string) (uint, error) {
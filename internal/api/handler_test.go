@@ -2,30 +2,44 @@ package api
 
 import (
 	"api_server/internal/domain"
+	"api_server/internal/mail"
 	"api_server/internal/repository/memory"
 	"api_server/internal/service"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 var (
 	testUsersCount = 3
+	testPassword   = "test-password"
 	repo           = memory.NewMockMemoryUserRepository()
-	svc            = service.NewUserService(repo)
+	policy         = service.NewPolicy(repo)
+	audit          = service.NewAuditService(memory.NewMockMemoryAuditRepository(), policy)
+	outbox         = mail.NewOutbox("")
+	verification   = &service.EmailVerification{Tokens: tokens, Mailer: outbox, URL: "http://example.com/verify-email"}
+	reset          = &service.PasswordReset{Tokens: tokens, Mailer: outbox, URL: "http://example.com/reset-password"}
+	svc            = service.NewUserService(repo, memory.NewMockMemoryUserHistoryRepository(), policy, audit, memory.NewMockMemoryTransactor(), verification, reset, nil)
 	handler        = func(s *service.UserService) *Handler {
 		h := &Handler{
 			userService: s,
 		}
 		for i := 1; i <= testUsersCount; i++ {
 			_, err := h.userService.CreateUser(
+				context.Background(),
 				fmt.Sprintf("Test name %d", i),
 				fmt.Sprintf("test_%d@example.com", i),
 				uint(25*i),
+				testPassword,
+				domain.RoleMember,
 			)
 			if err != nil {
 				panic(err)
@@ -36,6 +50,38 @@ var (
 	}(svc)
 )
 
+// newTestRouter создаёт роутер с обработчиком ошибок, как в main.go
+func newTestRouter() *gin.Engine {
+	r := gin.Default()
+	r.Use(ErrorHandler())
+	return r
+}
+
+// createTestUser создаёт пользователя только для текущего теста
+// и безвозвратно удаляет его по завершении теста
+func createTestUser(t *testing.T, name, email string) *domain.User {
+	t.Helper()
+	user, err := svc.CreateUser(t.Context(), name, email, 30, testPassword, domain.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = svc.PurgeUser(context.Background(), user.ID, 0)
+	})
+	return user
+}
+
+// decodeData разбирает конверт Response и декодирует его поле data в v
+func decodeData(body []byte, v interface{}) error {
+	var response struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return err
+	}
+	return json.Unmarshal(response.Data, v)
+}
+
 func TestHandler_ParseUserId(t *testing.T) {
 	h := &Handler{}
 	tests := []struct {
@@ -61,7 +107,7 @@ func TestHandler_ParseUserId(t *testing.T) {
 }
 
 func TestHandler_Ping(t *testing.T) {
-	r := gin.Default()
+	r := newTestRouter()
 	// Создаём тестовый роутер
 	r.GET("/ping", handler.Ping)
 
@@ -87,7 +133,7 @@ func TestHandler_Ping(t *testing.T) {
 }
 
 func TestHandler_GetUsers(t *testing.T) {
-	r := gin.Default()
+	r := newTestRouter()
 	r.GET("/users", handler.GetUsers)
 	req, err := http.NewRequest(http.MethodGet, "/users", nil)
 	if err != nil {
@@ -99,7 +145,7 @@ func TestHandler_GetUsers(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
 	}
 	var gotUsers []domain.User
-	err = json.Unmarshal(w.Body.Bytes(), &gotUsers)
+	err = decodeData(w.Body.Bytes(), &gotUsers)
 	if err != nil {
 		t.Errorf("Error unmarshalling response body: %v", err)
 	}
@@ -119,8 +165,142 @@ func TestHandler_GetUsers(t *testing.T) {
 	}
 }
 
+func TestHandler_GetUsersPagination(t *testing.T) {
+	r := newTestRouter()
+	r.GET("/users", handler.GetUsers)
+	req, err := http.NewRequest(http.MethodGet, "/users?limit=2&sort=-age", nil)
+	if err != nil {
+		t.Errorf("Error creating request: %v", err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("X-Total-Count"); got != strconv.Itoa(testUsersCount) {
+		t.Errorf("handler returned wrong X-Total-Count: got %v want %v", got, testUsersCount)
+	}
+	var response Response
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Errorf("Error unmarshalling response body: %v", err)
+	}
+	if p := response.Meta.Pagination; p == nil || p.Total == nil || *p.Total != int64(testUsersCount) || p.Limit != 2 {
+		t.Errorf("handler returned unexpected pagination: got %v", w.Body.String())
+	}
+	var gotUsers []domain.User
+	err = decodeData(w.Body.Bytes(), &gotUsers)
+	if err != nil {
+		t.Errorf("Error unmarshalling response body: %v", err)
+	}
+	if len(gotUsers) != 2 {
+		t.Fatalf("handler returned wrong number of users: got %v want %v", len(gotUsers), 2)
+	}
+	if gotUsers[0].Age != 75 || gotUsers[1].Age != 50 {
+		t.Errorf("handler returned users in wrong order: got %v, %v", gotUsers[0].Age, gotUsers[1].Age)
+	}
+}
+
+func TestHandler_GetUsersFilter(t *testing.T) {
+	r := newTestRouter()
+	r.GET("/users", handler.GetUsers)
+	tests := []struct {
+		query    string
+		wantCode int
+		wantName string
+	}{
+		{"/users?name=NAME%203", http.StatusOK, "Test name 3"},
+		{"/users?min_age=40&max_age=60", http.StatusOK, "Test name 2"},
+		{"/users?email=test_1@example.com", http.StatusOK, "Test name 1"},
+		{"/users?sort=password", http.StatusBadRequest, ""},
+		{"/users?limit=-1", http.StatusBadRequest, ""},
+		{"/users?created_from=yesterday", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, tt.query, nil)
+		if err != nil {
+			t.Errorf("Error creating request: %v", err)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tt.query, w.Code, tt.wantCode)
+			continue
+		}
+		if tt.wantCode != http.StatusOK {
+			continue
+		}
+		var gotUsers []domain.User
+		err = decodeData(w.Body.Bytes(), &gotUsers)
+		if err != nil {
+			t.Errorf("Error unmarshalling response body: %v", err)
+		}
+		if len(gotUsers) != 1 || gotUsers[0].Name != tt.wantName {
+			t.Errorf("%s: handler returned unexpected body: got %v want %v", tt.query, gotUsers, tt.wantName)
+		}
+	}
+}
+
+func TestHandler_GetUsersCursor(t *testing.T) {
+	r := newTestRouter()
+	r.GET("/users", handler.GetUsers)
+
+	getPage := func(cursor string) (*httptest.ResponseRecorder, []domain.User) {
+		req, err := http.NewRequest(http.MethodGet, "/users?limit=1&sort=-age&cursor="+cursor, nil)
+		if err != nil {
+			t.Errorf("Error creating request: %v", err)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+		}
+		var gotUsers []domain.User
+		err = decodeData(w.Body.Bytes(), &gotUsers)
+		if err != nil {
+			t.Errorf("Error unmarshalling response body: %v", err)
+		}
+		if len(gotUsers) != 1 {
+			t.Fatalf("handler returned wrong number of users: got %v want %v", len(gotUsers), 1)
+		}
+		return w, gotUsers
+	}
+
+	// Проходим все страницы вперёд
+	var cursor string
+	var lastPage *httptest.ResponseRecorder
+	for _, wantAge := range []uint{75, 50, 25} {
+		w, gotUsers := getPage(cursor)
+		if gotUsers[0].Age != wantAge {
+			t.Errorf("handler returned unexpected user: got age %v want %v", gotUsers[0].Age, wantAge)
+		}
+		cursor = w.Header().Get("X-Next-Cursor")
+		lastPage = w
+	}
+	if cursor != "" {
+		t.Errorf("handler returned X-Next-Cursor on the last page: %v", cursor)
+	}
+
+	// Возвращаемся на страницу назад
+	_, gotUsers := getPage(lastPage.Header().Get("X-Prev-Cursor"))
+	if gotUsers[0].Age != 50 {
+		t.Errorf("handler returned unexpected user: got age %v want %v", gotUsers[0].Age, 50)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/users?cursor=broken", nil)
+	if err != nil {
+		t.Errorf("Error creating request: %v", err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusBadRequest)
+	}
+}
+
 func TestHandler_GetUserSuccess(t *testing.T) {
-	r := gin.Default()
+	r := newTestRouter()
 	// Создаём тестовый роутер
 	r.GET("/user/:id", handler.GetUser)
 
@@ -134,7 +314,7 @@ func TestHandler_GetUserSuccess(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
 	}
 	var gotUser domain.User
-	err = json.Unmarshal(w.Body.Bytes(), &gotUser)
+	err = decodeData(w.Body.Bytes(), &gotUser)
 	if err != nil {
 		t.Errorf("Error unmarshalling response body: %v", err)
 	}
@@ -149,7 +329,8 @@ func TestHandler_GetUserSuccess(t *testing.T) {
 }
 
 func TestHandler_GetUserError(t *testing.T) {
-	r := gin.Default()
+	r := newTestRouter()
+	r.Use(RequestID())
 	// Проверяем несуществующий id
 	r.GET("/user/:id", handler.GetUser)
 
@@ -157,14 +338,26 @@ func TestHandler_GetUserError(t *testing.T) {
 	if err != nil {
 		t.Errorf("Error creating request: %v", err)
 	}
+	req.Header.Set(RequestIDHeader, "test-request-id")
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 	if w.Code != http.StatusNotFound {
 		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusNotFound)
 	}
+	var response Response
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Errorf("Error unmarshalling response body: %v", err)
+	}
+	if response.Status != StatusError || response.Error == nil || response.Error.Code != CodeUserNotFound {
+		t.Errorf("handler returned unexpected body: got %v want error code %v", w.Body.String(), CodeUserNotFound)
+	}
+	if response.Meta.RequestID != "test-request-id" {
+		t.Errorf("handler returned wrong request id: got %v want %v", response.Meta.RequestID, "test-request-id")
+	}
 
 	// Проверяем передачу строки вместо числа
-	r2 := gin.Default()
+	r2 := newTestRouter()
 	r2.GET("/user/:id", handler.GetUser)
 	req, err = http.NewRequest(http.MethodGet, "/user/test", nil)
 	if err != nil {
@@ -177,11 +370,54 @@ func TestHandler_GetUserError(t *testing.T) {
 	}
 }
 
+func TestHandler_ErrorLocalization(t *testing.T) {
+	r := newTestRouter()
+	r.Use(Language(LanguageRussian))
+	r.GET("/user/:id", handler.GetUser)
+	r.POST("/user", handler.CreateUser)
+	tests := []struct {
+		method         string
+		target         string
+		body           string
+		acceptLanguage string
+		wantMessage    string
+		wantField      string
+	}{
+		{http.MethodGet, "/user/15", "", "", "Пользователь не найден", ""},
+		{http.MethodGet, "/user/15", "", "en-US,en;q=0.9,ru;q=0.8", "User not found", ""},
+		{http.MethodGet, "/user/15", "", "de", "Пользователь не найден", ""},
+		{http.MethodPost, "/user", `{"name": "Test", "age": 20, "password": "test-password"}`, "en", "Request validation failed", "email is a required field"},
+		{http.MethodPost, "/user", `{"name": "Test", "age": 20, "password": "test-password"}`, "ru", "Запрос не прошёл валидацию", "email обязательное поле"},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		if err != nil {
+			t.Errorf("Error creating request: %v", err)
+		}
+		req.Header.Set("Accept-Language", tt.acceptLanguage)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var response Response
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		if err != nil {
+			t.Errorf("Error unmarshalling response body: %v", err)
+		}
+		if response.Error == nil || response.Error.Message != tt.wantMessage {
+			t.Errorf("%s: handler returned unexpected body: got %v want message %v", tt.acceptLanguage, w.Body.String(), tt.wantMessage)
+			continue
+		}
+		if tt.wantField != "" && (len(response.Error.Fields) != 1 || response.Error.Fields[0].Message != tt.wantField) {
+			t.Errorf("%s: handler returned unexpected field errors: got %v want %v", tt.acceptLanguage, response.Error.Fields, tt.wantField)
+		}
+	}
+}
+
 func TestHandler_CreateUserSuccess(t *testing.T) {
 	// Успешное создание пользователя
-	r := gin.Default()
+	r := newTestRouter()
 	r.POST("/user", handler.CreateUser)
-	jsonBody := `{"name": "Test Name 4", "age": 100, "email": "test_4@example.com"}`
+	jsonBody := `{"name": "Test Name 4", "age": 100, "email": "test_4@example.com", "password": "test-password"}`
 	req, err := http.NewRequest(http.MethodPost, "/user", strings.NewReader(jsonBody))
 	if err != nil {
 		t.Errorf("Error creating request: %v", err)
@@ -192,7 +428,7 @@ func TestHandler_CreateUserSuccess(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusCreated)
 	}
 	var gotUser domain.User
-	err = json.Unmarshal(w.Body.Bytes(), &gotUser)
+	err = decodeData(w.Body.Bytes(), &gotUser)
 	if err != nil {
 		t.Errorf("Error unmarshalling response body: %v", err)
 	}
@@ -204,11 +440,14 @@ func TestHandler_CreateUserSuccess(t *testing.T) {
 	if gotUser.Name != wantUser.Name || gotUser.Email != wantUser.Email || gotUser.Age != wantUser.Age {
 		t.Errorf("handler returned unexpected body: got %v want %v", gotUser, wantUser)
 	}
+	t.Cleanup(func() {
+		_ = svc.PurgeUser(context.Background(), gotUser.ID, 0)
+	})
 }
 
 func TestHandler_CreateUserNotAllParams(t *testing.T) {
 	//Передача не всех параметров для создания
-	r := gin.Default()
+	r := newTestRouter()
 	r.POST("/user", handler.CreateUser)
 	jsonBody := `{"name": "Test Name"}`
 	req, err := http.NewRequest(http.MethodPost, "/user", strings.NewReader(jsonBody))
@@ -222,9 +461,32 @@ func TestHandler_CreateUserNotAllParams(t *testing.T) {
 	}
 }
 
+func TestHandler_CreateUserPasswordTooLong(t *testing.T) {
+	r := newTestRouter()
+	r.POST("/user", handler.CreateUser)
+	// 42 символа проходят max=72, но это 84 байта: bcrypt такой пароль не примет
+	jsonBody := `{"name": "Long password", "email": "long_password@example.com", "age": 30, "password": "` + strings.Repeat("пароль", 7) + `"}`
+	req, err := http.NewRequest(http.MethodPost, "/user", strings.NewReader(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", w.Code, http.StatusBadRequest, w.Body.String())
+	}
+	var response Response
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if e := response.Error; e == nil || e.Code != CodeValidationFailed || len(e.Fields) != 1 || e.Fields[0].Field != "password" {
+		t.Errorf("handler returned unexpected body: got %v want %s for password", w.Body.String(), CodeValidationFailed)
+	}
+}
+
 func TestHandler_CreateUserNotJsonRequest(t *testing.T) {
 	//Передача невалидной строки
-	r := gin.Default()
+	r := newTestRouter()
 	r.POST("/user", handler.CreateUser)
 	jsonBody := `test string`
 	req, err := http.NewRequest(http.MethodPost, "/user", strings.NewReader(jsonBody))
@@ -233,13 +495,79 @@ func TestHandler_CreateUserNotJsonRequest(t *testing.T) {
 	}
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
-	if w.Code != http.StatusInternalServerError {
-		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusInternalServerError)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusBadRequest)
+	}
+	var response Response
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Errorf("Error unmarshalling response body: %v", err)
+	}
+	if response.Error == nil || response.Error.Code != CodeMalformedRequest {
+		t.Errorf("handler returned unexpected body: got %v want error code %v", w.Body.String(), CodeMalformedRequest)
+	}
+}
+
+func TestHandler_CreateUserProblemDetails(t *testing.T) {
+	r := newTestRouter()
+	r.POST("/user", handler.CreateUser)
+	jsonBody := `{"name": "Test Name"}`
+	req, err := http.NewRequest(http.MethodPost, "/user", strings.NewReader(jsonBody))
+	if err != nil {
+		t.Errorf("Error creating request: %v", err)
+	}
+	req.Header.Set("Accept", ProblemContentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusBadRequest)
+	}
+	if got := w.Header().Get("Content-Type"); got != ProblemContentType {
+		t.Errorf("handler returned wrong content type: got %v want %v", got, ProblemContentType)
+	}
+	var problem Problem
+	err = json.Unmarshal(w.Body.Bytes(), &problem)
+	if err != nil {
+		t.Errorf("Error unmarshalling response body: %v", err)
+	}
+	if problem.Type != "/problems/validation-failed" || problem.Status != http.StatusBadRequest || problem.Instance != "/user" {
+		t.Errorf("handler returned unexpected problem: got %v", w.Body.String())
+	}
+	fields := map[string]bool{}
+	for _, fe := range problem.Errors {
+		fields[fe.Field] = true
+	}
+	if !fields["age"] || !fields["email"] {
+		t.Errorf("handler returned unexpected field errors: got %v", problem.Errors)
+	}
+}
+
+func TestHandler_CreateUserDuplicateEmail(t *testing.T) {
+	r := newTestRouter()
+	r.Use(ProblemDetails())
+	r.POST("/user", handler.CreateUser)
+	jsonBody := `{"name": "Duplicate", "age": 30, "email": "test_1@example.com", "password": "test-password"}`
+	req, err := http.NewRequest(http.MethodPost, "/user", strings.NewReader(jsonBody))
+	if err != nil {
+		t.Errorf("Error creating request: %v", err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusConflict)
+	}
+	var problem Problem
+	err = json.Unmarshal(w.Body.Bytes(), &problem)
+	if err != nil {
+		t.Errorf("Error unmarshalling response body: %v", err)
+	}
+	if problem.Code != CodeEmailTaken {
+		t.Errorf("handler returned wrong error code: got %v want %v", problem.Code, CodeEmailTaken)
 	}
 }
 
 func TestHandler_UpdateUserName(t *testing.T) {
-	r := gin.Default()
+	r := newTestRouter()
 	r.PATCH("/user/:id", handler.UpdateUser)
 	jsonBody := `{"name": "Updated test Name"}`
 	req, err := http.NewRequest(http.MethodPatch, "/user/2", strings.NewReader(jsonBody))
@@ -252,7 +580,7 @@ func TestHandler_UpdateUserName(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusCreated)
 	}
 	var gotUser domain.User
-	err = json.Unmarshal(w.Body.Bytes(), &gotUser)
+	err = decodeData(w.Body.Bytes(), &gotUser)
 	if err != nil {
 		t.Errorf("Error unmarshalling response body: %v", err)
 	}
@@ -267,7 +595,7 @@ func TestHandler_UpdateUserName(t *testing.T) {
 }
 
 func TestHandler_UpdateUserAge(t *testing.T) {
-	r := gin.Default()
+	r := newTestRouter()
 	r.PATCH("/user/:id", handler.UpdateUser)
 	jsonBody := `{"age": 20}`
 	req, err := http.NewRequest(http.MethodPatch, "/user/1", strings.NewReader(jsonBody))
@@ -280,7 +608,7 @@ func TestHandler_UpdateUserAge(t *testing.T) {
 		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
 	}
 	var gotUser domain.User
-	err = json.Unmarshal(w.Body.Bytes(), &gotUser)
+	err = decodeData(w.Body.Bytes(), &gotUser)
 	if err != nil {
 		t.Errorf("Error unmarshalling response body: %v", err)
 	}
@@ -294,10 +622,251 @@ func TestHandler_UpdateUserAge(t *testing.T) {
 	}
 }
 
+func TestHandler_UpdateUserInvalidAge(t *testing.T) {
+	user := createTestUser(t, "Age fixture", "age_fixture@example.com")
+	path := fmt.Sprintf("/user/%d", user.ID)
+
+	r := newTestRouter()
+	r.PATCH("/user/:id", handler.UpdateUser)
+	tests := []struct {
+		body      string
+		wantCode  int
+		wantAge   uint
+		wantError ErrorCode
+	}{
+		{`{"age": 5}`, http.StatusBadRequest, 0, CodeValidationFailed},
+		{`{"age": 14}`, http.StatusOK, 14, ""},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodPatch, path, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v: %s", tt.body, w.Code, tt.wantCode, w.Body.String())
+			continue
+		}
+		var response Response
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if tt.wantError != "" && (response.Error == nil || response.Error.Code != tt.wantError || len(response.Error.Fields) != 1 || response.Error.Fields[0].Field != "age") {
+			t.Errorf("%s: handler returned unexpected body: got %v want %v for age", tt.body, w.Body.String(), tt.wantError)
+		}
+		if tt.wantAge != 0 {
+			var gotUser domain.User
+			if err := decodeData(w.Body.Bytes(), &gotUser); err != nil {
+				t.Fatal(err)
+			}
+			if gotUser.Age != tt.wantAge {
+				t.Errorf("%s: age = %d, want %d", tt.body, gotUser.Age, tt.wantAge)
+			}
+		}
+	}
+}
+
+func TestHandler_UserHistory(t *testing.T) {
+	r := newTestRouter()
+	r.GET("/user/:id/history", handler.GetUserHistory)
+	r.GET("/user/:id/history/:version", handler.GetUserVersion)
+	r.POST("/user/:id/revert/:version", handler.RevertUser)
+
+	history := func() []domain.UserVersion {
+		req, err := http.NewRequest(http.MethodGet, "/user/2/history", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+		}
+		var versions []domain.UserVersion
+		if err := decodeData(w.Body.Bytes(), &versions); err != nil {
+			t.Fatalf("Error unmarshalling response body: %v", err)
+		}
+		return versions
+	}
+
+	versions := history()
+	if len(versions) == 0 || versions[0].Name != "Test name 2" {
+		t.Fatalf("handler returned unexpected history: got %+v", versions)
+	}
+	latest := strconv.Itoa(int(versions[0].Version))
+
+	tests := []struct {
+		method    string
+		path      string
+		wantCode  int
+		wantError ErrorCode
+	}{
+		{http.MethodGet, "/user/2/history/" + latest, http.StatusOK, ""},
+		{http.MethodGet, "/user/2/history/999", http.StatusNotFound, CodeVersionNotFound},
+		{http.MethodGet, "/user/2/history/abc", http.StatusBadRequest, CodeInvalidVersion},
+		{http.MethodGet, "/user/999/history", http.StatusNotFound, CodeUserNotFound},
+		{http.MethodPost, "/user/2/revert/" + latest, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.wantCode {
+			t.Errorf("%s %s: handler returned wrong status code: got %v want %v", tt.method, tt.path, w.Code, tt.wantCode)
+			continue
+		}
+		var response Response
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Errorf("Error unmarshalling response body: %v", err)
+		}
+		if tt.wantError != "" && (response.Error == nil || response.Error.Code != tt.wantError) {
+			t.Errorf("%s %s: handler returned unexpected body: got %v want %v", tt.method, tt.path, w.Body.String(), tt.wantError)
+		}
+	}
+
+	user, err := svc.GetUserByID(context.Background(), 2)
+	if err != nil || user.Name != "Test name 2" {
+		t.Errorf("user was not reverted: got %v, %v", user, err)
+	}
+	reverted := history()
+	if len(reverted) != len(versions)+1 || reverted[0].Name != "Updated test Name" {
+		t.Errorf("revert did not save the current state as a new version: got %+v", reverted)
+	}
+	if len(reverted) > 0 && reverted[0].Version != user.Version-1 {
+		t.Errorf("snapshot version = %d, want the version before revert %d", reverted[0].Version, user.Version-1)
+	}
+}
+
+func TestHandler_ConditionalUpdate(t *testing.T) {
+	r := newTestRouter()
+	r.GET("/user/:id", handler.GetUser)
+	r.PATCH("/user/:id", handler.UpdateUser)
+	r.DELETE("/user/:id", handler.DeleteUser)
+
+	do := func(method, ifMatch, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, "/user/3", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	etag := do(http.MethodGet, "", "").Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("handler did not return ETag")
+	}
+
+	w := do(http.MethodPatch, etag, `{"age": 76}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+	newETag := w.Header().Get("ETag")
+	if newETag == "" || newETag == etag {
+		t.Errorf("handler returned unexpected ETag after update: got %q, was %q", newETag, etag)
+	}
+
+	tests := []struct {
+		method  string
+		ifMatch string
+	}{
+		{http.MethodPatch, etag},
+		{http.MethodPatch, "W/" + newETag},
+		{http.MethodPatch, "broken"},
+		{http.MethodDelete, etag},
+	}
+	for _, tt := range tests {
+		w := do(tt.method, tt.ifMatch, `{"age": 77}`)
+		if w.Code != http.StatusPreconditionFailed {
+			t.Errorf("%s %s: handler returned wrong status code: got %v want %v", tt.method, tt.ifMatch, w.Code, http.StatusPreconditionFailed)
+			continue
+		}
+		var response Response
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Errorf("Error unmarshalling response body: %v", err)
+		}
+		if response.Error == nil || response.Error.Code != CodePreconditionFailed {
+			t.Errorf("%s %s: handler returned unexpected body: got %v", tt.method, tt.ifMatch, w.Body.String())
+		}
+	}
+
+	user, err := svc.GetUserByID(context.Background(), 3)
+	if err != nil || user.Age != 76 {
+		t.Errorf("stale request changed the user: got %v, %v", user, err)
+	}
+}
+
+func TestHandler_ConditionalGet(t *testing.T) {
+	r := newTestRouter()
+	r.GET("/user/:id", handler.GetUser)
+	r.GET("/users", handler.GetUsers)
+
+	get := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/user/3", nil)
+	etag, modified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	if etag == "" || modified == "" || w.Header().Get("Cache-Control") != DefaultCacheControl {
+		t.Fatalf("handler returned unexpected validators: %v", w.Header())
+	}
+	modifiedAt, err := http.ParseTime(modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listETag := get("/users", nil).Header().Get("ETag")
+	if !strings.HasPrefix(listETag, `W/"`) {
+		t.Fatalf("handler returned unexpected list ETag: %q", listETag)
+	}
+
+	tests := []struct {
+		path     string
+		headers  map[string]string
+		wantCode int
+	}{
+		{"/user/3", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"/user/3", map[string]string{"If-None-Match": `"0", ` + etag}, http.StatusNotModified},
+		{"/user/3", map[string]string{"If-None-Match": `"0"`}, http.StatusOK},
+		{"/user/3", map[string]string{"If-Modified-Since": modified}, http.StatusNotModified},
+		{"/user/3", map[string]string{"If-Modified-Since": modifiedAt.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK},
+		// If-None-Match важнее If-Modified-Since
+		{"/user/3", map[string]string{"If-None-Match": `"0"`, "If-Modified-Since": modified}, http.StatusOK},
+		{"/users", map[string]string{"If-None-Match": listETag}, http.StatusNotModified},
+		{"/users?limit=1", map[string]string{"If-None-Match": listETag}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := get(tt.path, tt.headers)
+		if w.Code != tt.wantCode {
+			t.Errorf("%s %v: handler returned wrong status code: got %v want %v", tt.path, tt.headers, w.Code, tt.wantCode)
+		}
+		if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("%s %v: handler returned body with 304: %v", tt.path, tt.headers, w.Body.String())
+		}
+	}
+}
+
 func TestHandler_DeleteUser(t *testing.T) {
-	r := gin.Default()
+	user := createTestUser(t, "Delete fixture", "delete_fixture@example.com")
+	r := newTestRouter()
 	r.DELETE("/user/:id", handler.DeleteUser)
-	req, err := http.NewRequest(http.MethodDelete, "/user/1", nil)
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("/user/%d", user.ID), nil)
 	if err != nil {
 		t.Errorf("Error creating request: %v", err)
 	}
@@ -307,3 +876,195 @@ func TestHandler_DeleteUser(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
 	}
 }
+
+func TestTimeout_CancelsRequestContext(t *testing.T) {
+	r := newTestRouter()
+	r.Use(Timeout(time.Millisecond, map[string]time.Duration{"/unlimited": 0}))
+	r.GET("/slow", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		c.String(http.StatusOK, c.Request.Context().Err().Error())
+	})
+	r.GET("/unlimited", func(c *gin.Context) {
+		_, ok := c.Request.Context().Deadline()
+		c.String(http.StatusOK, strconv.FormatBool(ok))
+	})
+
+	get := func(path string) string {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	if body := get("/slow"); body != context.DeadlineExceeded.Error() {
+		t.Errorf("handler returned unexpected body: got %v want %v", body, context.DeadlineExceeded.Error())
+	}
+	if body := get("/unlimited"); body != "false" {
+		t.Errorf("route without a timeout has a deadline")
+	}
+}
+
+func TestHandler_DeleteUserNotFound(t *testing.T) {
+	user := createTestUser(t, "Deleted fixture", "deleted_fixture@example.com")
+	if err := svc.DeleteUser(t.Context(), user.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRouter()
+	r.DELETE("/user/:id", handler.DeleteUser)
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("/user/%d", user.ID), nil)
+	if err != nil {
+		t.Errorf("Error creating request: %v", err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestHandler_GetUsersDeleted(t *testing.T) {
+	alive := createTestUser(t, "Listed fixture alive", "listed_alive@example.com")
+	deleted := createTestUser(t, "Listed fixture deleted", "listed_deleted@example.com")
+	if err := svc.DeleteUser(t.Context(), deleted.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	r := newTestRouter()
+	r.GET("/users", handler.GetUsers)
+	tests := []struct {
+		query   string
+		wantIDs []uint
+	}{
+		{"", []uint{alive.ID}},
+		{"&only_deleted=true", []uint{deleted.ID}},
+		{"&include_deleted=true", []uint{alive.ID, deleted.ID}},
+	}
+	for _, tt := range tests {
+		// Фильтр по имени оставляет только пользователей этого теста
+		req, err := http.NewRequest(http.MethodGet, "/users?name=listed%20fixture"+tt.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("%q: handler returned wrong status code: got %v want %v", tt.query, w.Code, http.StatusOK)
+			continue
+		}
+		var users []domain.User
+		if err := decodeData(w.Body.Bytes(), &users); err != nil {
+			t.Errorf("Error unmarshalling response body: %v", err)
+		}
+		var gotIDs []uint
+		for _, u := range users {
+			gotIDs = append(gotIDs, u.ID)
+		}
+		if fmt.Sprint(gotIDs) != fmt.Sprint(tt.wantIDs) {
+			t.Errorf("%q: handler returned unexpected users: got %v want %v", tt.query, gotIDs, tt.wantIDs)
+		}
+	}
+}
+
+func TestHandler_RestoreUser(t *testing.T) {
+	user := createTestUser(t, "Restore fixture", "restore_fixture@example.com")
+	if err := svc.DeleteUser(t.Context(), user.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("/user/%d/restore", user.ID)
+
+	r := newTestRouter()
+	r.POST("/user/:id/restore", handler.RestoreUser)
+	tests := []struct {
+		path      string
+		wantCode  int
+		wantError ErrorCode
+	}{
+		{path, http.StatusOK, ""},
+		{path, http.StatusConflict, CodeUserNotDeleted},
+		{"/user/999/restore", http.StatusNotFound, CodeUserNotFound},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodPost, tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tt.path, w.Code, tt.wantCode)
+			continue
+		}
+		var response Response
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Errorf("Error unmarshalling response body: %v", err)
+		}
+		if tt.wantError != "" && (response.Error == nil || response.Error.Code != tt.wantError) {
+			t.Errorf("%s: handler returned unexpected body: got %v want %v", tt.path, w.Body.String(), tt.wantError)
+		}
+	}
+
+	restored, err := svc.GetUserByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("restored user is not available: %v", err)
+	}
+	// Удаление и восстановление меняют версию, иначе If-None-Match со старым ETag дал бы 304
+	if restored.Version != user.Version+2 {
+		t.Errorf("version after delete and restore = %d, want %d", restored.Version, user.Version+2)
+	}
+	if !restored.UpdatedAt.After(user.UpdatedAt) {
+		t.Errorf("UpdatedAt after delete and restore = %v, want after %v", restored.UpdatedAt, user.UpdatedAt)
+	}
+}
+
+func TestHandler_PurgeUser(t *testing.T) {
+	user := createTestUser(t, "Purge fixture", "purge_fixture@example.com")
+	path := fmt.Sprintf("/user/%d", user.ID)
+
+	r := newTestRouter()
+	r.DELETE("/user/:id", handler.DeleteUser)
+	r.POST("/user/:id/restore", handler.RestoreUser)
+	tests := []struct {
+		method   string
+		path     string
+		wantCode int
+	}{
+		{http.MethodDelete, path + "?hard=maybe", http.StatusBadRequest},
+		{http.MethodDelete, path + "?hard=true", http.StatusOK},
+		{http.MethodPost, path + "/restore", http.StatusNotFound},
+		{http.MethodDelete, path + "?hard=true", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.wantCode {
+			t.Errorf("%s %s: handler returned wrong status code: got %v want %v", tt.method, tt.path, w.Code, tt.wantCode)
+		}
+	}
+}
+
+func TestUserService_PurgeDeleted(t *testing.T) {
+	ctx := context.Background()
+	user := createTestUser(t, "Retention fixture", "retention_fixture@example.com")
+	if err := svc.DeleteUser(ctx, user.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := svc.PurgeDeleted(ctx, time.Hour)
+	if err != nil || purged != 0 {
+		t.Errorf("PurgeDeleted purged users within retention: got %v, %v", purged, err)
+	}
+	// Кроме пользователя этого теста могут быть удалены и пользователи других тестов
+	purged, err = svc.PurgeDeleted(ctx, 0)
+	if err != nil || purged < 1 {
+		t.Errorf("PurgeDeleted returned unexpected result: got %v, %v want at least 1", purged, err)
+	}
+	if _, err := svc.RestoreUser(ctx, user.ID); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("purged user can be restored: %v", err)
+	}
+}
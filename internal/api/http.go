@@ -1,9 +1,37 @@
 package api
 
+import "time"
+
+// Response — общий конверт для всех ответов API
 type Response struct {
-	Status string `json:"status"`
-	Code   int    `json:"code"`
-	Data   string `json:"data"`
+	Status string      `json:"status"`
+	Code   int         `json:"code"`
+	Data   interface{} `json:"data,omitempty"`
+	Error  *Error      `json:"error,omitempty"`
+	Meta   Meta        `json:"meta"`
+}
+
+type Error struct {
+	// Code — стабильный машиночитаемый код ошибки
+	Code ErrorCode `json:"code" example:"USER_NOT_FOUND"`
+	// Message — описание ошибки для человека
+	Message string `json:"message"`
+	// Fields — ошибки валидации отдельных полей запроса
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+type Meta struct {
+	RequestID  string      `json:"request_id"`
+	Timestamp  time.Time   `json:"timestamp"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
 }
 
 const (
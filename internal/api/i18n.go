@@ -0,0 +1,228 @@
+package api
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/export"
+	"api_server/internal/repository"
+	"api_server/internal/service"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/ru"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	ruTranslations "github.com/go-playground/validator/v10/translations/ru"
+	"strconv"
+	"strings"
+)
+
+const (
+	LanguageRussian = "ru"
+	LanguageEnglish = "en"
+
+	DefaultLanguage = LanguageRussian
+	languageKey     = "language"
+)
+
+// message — сообщение об ошибке err и всех ошибках, которые её оборачивают
+type message struct {
+	err  error
+	text string
+}
+
+// messages — каталог сообщений об ошибках по языкам. Сообщение выбирается по первой
+// ошибке из списка, которую оборачивает ответ, поэтому конкретные ошибки стоят раньше
+// общих. Порядок ошибок во всех языках одинаковый.
+var messages = map[string][]message{
+	LanguageRussian: {
+		{service.ErrNotFound, "Пользователь не найден"},
+		{service.ErrInvalidAge, "Возраст пользователя не может быть менее 14 лет"},
+		{service.ErrInvalidRole, "Неизвестная роль пользователя"},
+		{service.ErrPasswordTooLong, "Пароль не может быть длиннее 72 байт"},
+		{service.ErrNotDeleted, "Пользователь не удалён"},
+		{service.ErrVersionNotFound, "Версия пользователя не найдена"},
+		{service.ErrExportNotFound, "Выгрузка не найдена"},
+		{service.ErrExportNotReady, "Выгрузка ещё не завершена"},
+		{export.ErrUnknownFormat, "Неизвестный формат выгрузки"},
+		{service.ErrVersionMismatch, "Пользователь был изменён другим запросом; получите актуальную версию и повторите"},
+		{errInvalidVersion, "Некорректный номер версии"},
+		{errInvalidIdempotencyKey, "Ключ идемпотентности должен быть не длиннее 255 символов"},
+		{errUnsupportedFormat, "Формат файла не поддерживается"},
+		{errImportTooLarge, "Слишком много строк в файле импорта"},
+		{errBatchTooLarge, "Слишком много операций в пакете"},
+		{errBatchAborted, "Операция не выполнена: другая операция пакета завершилась ошибкой"},
+		{errRateLimited, "Слишком много запросов; повторите позже"},
+		{service.ErrIdempotencyKeyReused, "Ключ идемпотентности уже использован с другим запросом"},
+		{service.ErrIdempotencyKeyInProgress, "Запрос с этим ключом идемпотентности ещё выполняется"},
+		{service.ErrIDNotTransmitted, "ID пользователя не передан"},
+		{service.ErrIDNotValid, "Некорректный ID пользователя"},
+		{service.ErrEmailTaken, "Пользователь с таким email уже существует"},
+		{service.ErrEmailAlreadyVerified, "Email уже подтверждён"},
+		{service.ErrInvalidVerificationToken, "Ссылка для подтверждения email недействительна"},
+		{service.ErrInvalidResetToken, "Ссылка для сброса пароля недействительна"},
+		{repository.ErrInvalidSort, "Сортировка по этому полю не поддерживается"},
+		{repository.ErrInvalidCursor, "Некорректный курсор"},
+		{repository.ErrCursorSort, "Выборка по курсору поддерживает сортировку только по одному полю"},
+		{repository.ErrCursorOffset, "Параметр offset нельзя использовать вместе с cursor"},
+		{errInvalidQuery, "Некорректные параметры запроса"},
+		{errMalformedRequest, "Некорректное тело запроса"},
+		{errValidationFailed, "Запрос не прошёл валидацию"},
+		{errInternal, "Внутренняя ошибка сервера"},
+		{errConflict, "Конфликт с текущим состоянием ресурса"},
+		{errPreconditionFailed, "Ресурс был изменён: условие запроса не выполнено"},
+		{errUnprocessable, "Запрос не может быть выполнен"},
+		{errAuthenticationRequired, "Требуется аутентификация"},
+		{service.ErrInvalidCredentials, "Неверный email или пароль"},
+		{auth.ErrInvalidToken, "Недействительный токен"},
+		{auth.ErrTokenExpired, "Срок действия токена истёк"},
+		{auth.ErrInvalidAPIKey, "Недействительный API-ключ"},
+		{auth.ErrUnknownScope, "Неизвестное право доступа"},
+		{auth.ErrInsufficientScope, "У API-ключа нет права на это действие"},
+		{service.ErrForbidden, "Недостаточно прав для этого действия"},
+		{service.ErrAPIKeyNotFound, "API-ключ не найден"},
+		{errNotFound, "Ресурс не найден"},
+	},
+	LanguageEnglish: {
+		{service.ErrNotFound, "User not found"},
+		{service.ErrInvalidAge, "User must be at least 14 years old"},
+		{service.ErrInvalidRole, "Unknown user role"},
+		{service.ErrPasswordTooLong, "Password must be at most 72 bytes long"},
+		{service.ErrNotDeleted, "User is not deleted"},
+		{service.ErrVersionNotFound, "User version not found"},
+		{service.ErrExportNotFound, "Export not found"},
+		{service.ErrExportNotReady, "Export is not completed yet"},
+		{export.ErrUnknownFormat, "Unknown export format"},
+		{service.ErrVersionMismatch, "User was modified by another request; fetch the current version and retry"},
+		{errInvalidVersion, "Invalid version number"},
+		{errInvalidIdempotencyKey, "Idempotency key must be at most 255 characters long"},
+		{errUnsupportedFormat, "Unsupported file format"},
+		{errImportTooLarge, "Too many rows in the import file"},
+		{errBatchTooLarge, "Too many operations in the batch"},
+		{errBatchAborted, "Operation was not applied because another operation in the batch failed"},
+		{errRateLimited, "Too many requests; try again later"},
+		{service.ErrIdempotencyKeyReused, "Idempotency key was already used with a different request"},
+		{service.ErrIdempotencyKeyInProgress, "A request with this idempotency key is still in progress"},
+		{service.ErrIDNotTransmitted, "User ID is missing"},
+		{service.ErrIDNotValid, "Invalid user ID"},
+		{service.ErrEmailTaken, "A user with this email already exists"},
+		{service.ErrEmailAlreadyVerified, "Email is already verified"},
+		{service.ErrInvalidVerificationToken, "The email confirmation link is invalid"},
+		{service.ErrInvalidResetToken, "The password reset link is invalid"},
+		{repository.ErrInvalidSort, "Sorting by this field is not supported"},
+		{repository.ErrInvalidCursor, "Invalid cursor"},
+		{repository.ErrCursorSort, "Cursor pagination supports sorting by a single field only"},
+		{repository.ErrCursorOffset, "The offset parameter cannot be combined with cursor"},
+		{errInvalidQuery, "Invalid query parameters"},
+		{errMalformedRequest, "Malformed request body"},
+		{errValidationFailed, "Request validation failed"},
+		{errInternal, "Internal server error"},
+		{errConflict, "The request conflicts with the current state of the resource"},
+		{errPreconditionFailed, "The resource has been modified: precondition failed"},
+		{errUnprocessable, "The request cannot be processed"},
+		{errAuthenticationRequired, "Authentication required"},
+		{service.ErrInvalidCredentials, "Invalid email or password"},
+		{auth.ErrInvalidToken, "Invalid token"},
+		{auth.ErrTokenExpired, "Token has expired"},
+		{auth.ErrInvalidAPIKey, "Invalid API key"},
+		{auth.ErrUnknownScope, "Unknown scope"},
+		{auth.ErrInsufficientScope, "The API key lacks the scope required for this action"},
+		{service.ErrForbidden, "You are not allowed to perform this action"},
+		{service.ErrAPIKeyNotFound, "API key not found"},
+		{errNotFound, "Resource not found"},
+	},
+}
+
+var universalTranslator = newUniversalTranslator()
+
+func newUniversalTranslator() *ut.UniversalTranslator {
+	uni := ut.New(ru.New(), ru.New(), en.New())
+
+	ruTrans, _ := uni.GetTranslator(LanguageRussian)
+	if err := ruTranslations.RegisterDefaultTranslations(validate, ruTrans); err != nil {
+		panic(err)
+	}
+	enTrans, _ := uni.GetTranslator(LanguageEnglish)
+	if err := enTranslations.RegisterDefaultTranslations(validate, enTrans); err != nil {
+		panic(err)
+	}
+
+	return uni
+}
+
+// translator переводит ошибки на язык запроса
+type translator struct {
+	language   string
+	validation ut.Translator
+}
+
+func newTranslator(language string) translator {
+	trans, _ := universalTranslator.GetTranslator(language)
+	return translator{language: language, validation: trans}
+}
+
+// message возвращает сообщение из каталога для ошибки err
+func (t translator) message(err error) string {
+	return t.messageOr(err, errInternal)
+}
+
+// messageOr возвращает сообщение для err, а если его нет в каталоге — для fallback
+func (t translator) messageOr(err, fallback error) string {
+	for _, candidate := range []error{err, fallback} {
+		for _, m := range messages[t.language] {
+			if errors.Is(candidate, m.err) {
+				return m.text
+			}
+		}
+	}
+	return ""
+}
+
+func (t translator) field(fe validator.FieldError) string {
+	return fe.Translate(t.validation)
+}
+
+// Language выбирает язык ответа по заголовку Accept-Language.
+// Если ни один из запрошенных языков не поддерживается, используется defaultLanguage.
+func Language(defaultLanguage string) gin.HandlerFunc {
+	if _, ok := messages[defaultLanguage]; !ok {
+		defaultLanguage = DefaultLanguage
+	}
+	return func(c *gin.Context) {
+		language := negotiateLanguage(c.GetHeader("Accept-Language"), defaultLanguage)
+		c.Set(languageKey, language)
+		c.Header("Content-Language", language)
+		c.Next()
+	}
+}
+
+func requestTranslator(c *gin.Context) translator {
+	language := c.GetString(languageKey)
+	if language == "" {
+		language = negotiateLanguage(c.GetHeader("Accept-Language"), DefaultLanguage)
+	}
+	return newTranslator(language)
+}
+
+// negotiateLanguage выбирает поддерживаемый язык с наибольшим весом q
+// из заголовка вида "en-US,en;q=0.9,ru;q=0.8".
+func negotiateLanguage(header, defaultLanguage string) string {
+	best, bestQ := defaultLanguage, 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		base, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if _, ok := messages[base]; ok && q > bestQ {
+			best, bestQ = base, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"api_server/internal/service"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestMessages_SameOrderInAllLanguages(t *testing.T) {
+	want := messages[DefaultLanguage]
+	for language, list := range messages {
+		if len(list) != len(want) {
+			t.Errorf("%s: %d messages, want %d", language, len(list), len(want))
+			continue
+		}
+		for i := range list {
+			if list[i].err != want[i].err {
+				t.Errorf("%s: message %d is for %v, want %v", language, i, list[i].err, want[i].err)
+			}
+		}
+	}
+}
+
+func TestTranslator_MessageFirstMatchWins(t *testing.T) {
+	// Ошибка оборачивает и общую, и конкретную ошибку: побеждает конкретная
+	err := fmt.Errorf("%w: %w", errValidationFailed, service.ErrInvalidAge)
+	tr := newTranslator(LanguageEnglish)
+	if got, want := tr.message(err), "User must be at least 14 years old"; got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+	if got, want := tr.messageOr(errors.New("unknown"), errInternal), "Internal server error"; got != want {
+		t.Errorf("fallback message = %q, want %q", got, want)
+	}
+}
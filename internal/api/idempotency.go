@@ -0,0 +1,125 @@
+package api
+
+import (
+	"api_server/internal/service"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// replayedHeaders — заголовки ответа, которые сохраняются и повторяются вместе с телом
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// Idempotency делает запрос с заголовком Idempotency-Key безопасным для повтора:
+// первый ответ (статус, тело и заголовки из replayedHeaders) сохраняется
+// и возвращается как есть на повторы с тем же ключом и тем же запросом.
+// Ответы 5xx не сохраняются, чтобы запрос можно было повторить.
+// Middleware должен стоять после Authenticate: ключи действуют в пределах субъекта.
+func Idempotency(s *service.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			_ = c.Error(errInvalidIdempotencyKey)
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("%w: %v", errMalformedRequest, err))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		record, replay, err := s.Begin(c.Request.Context(), key, requestHash(c.Request, body))
+		if err != nil {
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+		if replay {
+			for name, value := range record.Headers {
+				c.Header(name, value)
+			}
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(record.StatusCode, record.Headers["Content-Type"], record.Body)
+			c.Abort()
+			return
+		}
+
+		// Ключ освобождается и при панике обработчика, и после отмены контекста
+		// запроса по таймауту, иначе повторы получали бы конфликт до истечения блокировки
+		ctx := context.WithoutCancel(c.Request.Context())
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if err := s.Abort(ctx, record); err != nil {
+				slog.ErrorContext(ctx, "release idempotency key", "error", err)
+			}
+		}()
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		// Ошибку нужно записать здесь, а не в ErrorHandler, чтобы сохранить и её
+		flushError(c)
+
+		if recorder.Status() >= http.StatusInternalServerError {
+			return
+		}
+		headers := make(map[string]string)
+		for _, name := range replayedHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		if err := s.Complete(ctx, record, recorder.Status(), headers, recorder.body.Bytes()); err != nil {
+			_ = c.Error(err)
+			return
+		}
+		completed = true
+	}
+}
+
+// requestHash отличает запросы с одним ключом: метод, путь и тело
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// bodyRecorder дублирует тело ответа в буфер
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
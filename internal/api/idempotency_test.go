@@ -0,0 +1,104 @@
+package api
+
+import (
+	"api_server/internal/domain"
+	"api_server/internal/repository/memory"
+	"api_server/internal/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var idempotency = service.NewIdempotencyService(memory.NewMockMemoryIdempotencyRepository(), 0, 0)
+
+func postIdempotent(t *testing.T, r http.Handler, key, body string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(http.MethodPost, "/user", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(IdempotencyKeyHeader, key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency_Replay(t *testing.T) {
+	r := newTestRouter()
+	r.POST("/user", Idempotency(idempotency), handler.CreateUser)
+	body := `{"name": "Idempotent", "age": 30, "email": "idempotent@example.com", "password": "test-password"}`
+
+	first := postIdempotent(t, r, "create-idempotent", body)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", first.Code, http.StatusCreated)
+	}
+	var created domain.User
+	if err := decodeData(first.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = svc.PurgeUser(t.Context(), created.ID, 0)
+	}()
+
+	second := postIdempotent(t, r, "create-idempotent", body)
+	if second.Code != http.StatusCreated {
+		t.Errorf("replay returned wrong status code: got %v want %v", second.Code, http.StatusCreated)
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Errorf("replay returned different body: got %s want %s", second.Body.String(), first.Body.String())
+	}
+	if second.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("replay is not marked with %s", IdempotentReplayedHeader)
+	}
+	if second.Header().Get("ETag") != first.Header().Get("ETag") {
+		t.Errorf("replay returned different ETag: got %q want %q", second.Header().Get("ETag"), first.Header().Get("ETag"))
+	}
+
+	other := postIdempotent(t, r, "create-idempotent", strings.Replace(body, "30", "31", 1))
+	if other.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key returned wrong status code: got %v want %v", other.Code, http.StatusUnprocessableEntity)
+	}
+	if !strings.Contains(other.Body.String(), string(CodeIdempotencyKeyReused)) {
+		t.Errorf("reused key returned unexpected body: %s", other.Body.String())
+	}
+}
+
+func TestIdempotency_ErrorIsStored(t *testing.T) {
+	r := newTestRouter()
+	r.POST("/user", Idempotency(idempotency), handler.CreateUser)
+	body := `{"age": 30, "email": "idempotent-invalid@example.com", "password": "test-password"}`
+
+	first := postIdempotent(t, r, "create-invalid", body)
+	if first.Code != http.StatusBadRequest {
+		t.Fatalf("handler returned wrong status code: got %v want %v", first.Code, http.StatusBadRequest)
+	}
+	second := postIdempotent(t, r, "create-invalid", body)
+	if second.Code != http.StatusBadRequest || second.Body.String() != first.Body.String() {
+		t.Errorf("replay returned %v %s, want %v %s", second.Code, second.Body.String(), first.Code, first.Body.String())
+	}
+	if second.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("replay is not marked with %s", IdempotentReplayedHeader)
+	}
+}
+
+func TestIdempotency_PanicReleasesKey(t *testing.T) {
+	r := newTestRouter()
+	calls := 0
+	r.POST("/user", Idempotency(idempotency), func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("handler failed")
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	first := postIdempotent(t, r, "create-panic", `{}`)
+	if first.Code != http.StatusInternalServerError {
+		t.Fatalf("handler returned wrong status code: got %v want %v", first.Code, http.StatusInternalServerError)
+	}
+	second := postIdempotent(t, r, "create-panic", `{}`)
+	if second.Code != http.StatusNoContent {
+		t.Errorf("retry after panic returned wrong status code: got %v want %v", second.Code, http.StatusNoContent)
+	}
+}
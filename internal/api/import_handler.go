@@ -0,0 +1,312 @@
+package api
+
+import (
+	"api_server/internal/domain"
+	"api_server/internal/service"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxImportRows — максимальное число строк в файле импорта по умолчанию
+	MaxImportRows = 10000
+
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+
+	ImportModeAtomic     = "atomic"
+	ImportModeBestEffort = "best_effort"
+
+	// importFileField — поле multipart-формы с файлом импорта
+	importFileField = "file"
+)
+
+// importFormats сопоставляет Content-Type и расширение файла с форматом импорта
+var importFormats = map[string]string{
+	"text/csv":             ImportFormatCSV,
+	"application/csv":      ImportFormatCSV,
+	"application/x-ndjson": ImportFormatNDJSON,
+	"application/ndjson":   ImportFormatNDJSON,
+	"application/jsonl":    ImportFormatNDJSON,
+	".csv":                 ImportFormatCSV,
+	".ndjson":              ImportFormatNDJSON,
+	".jsonl":               ImportFormatNDJSON,
+}
+
+type ImportUsersRequest struct {
+	DryRun bool   `form:"dry_run"`
+	Mode   string `form:"mode" validate:"omitempty,oneof=atomic best_effort"`
+	Format string `form:"format" validate:"omitempty,oneof=csv ndjson"`
+}
+
+type ImportRowResponse struct {
+	// Line — номер строки в файле
+	Line   int                  `json:"line"`
+	Email  string               `json:"email,omitempty"`
+	Status service.ImportStatus `json:"status" enums:"created,valid,skipped,invalid,failed"`
+	UserID uint                 `json:"user_id,omitempty"`
+	// Error — причина, по которой строка пропущена или отклонена
+	Error *Error `json:"error,omitempty"`
+}
+
+type ImportResponse struct {
+	DryRun bool   `json:"dry_run"`
+	Mode   string `json:"mode"`
+	// Committed сообщает, были ли изменения записаны в базу
+	Committed bool                `json:"committed"`
+	Created   int                 `json:"created"`
+	Valid     int                 `json:"valid"`
+	Skipped   int                 `json:"skipped"`
+	Invalid   int                 `json:"invalid"`
+	Failed    int                 `json:"failed"`
+	Rows      []ImportRowResponse `json:"rows"`
+}
+
+// ImportUsers godoc
+// @Summary      Импорт пользователей из CSV или NDJSON
+// @Description  Файл передаётся телом запроса или полем file multipart-формы. Формат определяется
+// @Description  параметром format, затем по Content-Type или расширению файла.
+// @Description  CSV должен начинаться со строки заголовков: name, email, age, password, role.
+// @Description  Каждая строка проверяется так же, как в POST /user; пользователи с занятым email пропускаются.
+// @Description  В режиме atomic пользователи создаются в одной транзакции и только если в файле нет
+// @Description  некорректных строк; в режиме best_effort каждая корректная строка создаётся независимо,
+// @Description  а строки, которые не удалось записать из-за внутренней ошибки, получают статус failed.
+// @Description  Запрос ограничен своим таймаутом IMPORT_TIMEOUT, рассчитанным на MAX_IMPORT_ROWS строк.
+// @Tags         user
+// @Accept       text/csv,application/x-ndjson,multipart/form-data
+// @Produce      json
+// @Param        dry_run  query     bool    false  "Только проверить файл, ничего не создавая"
+// @Param        mode     query     string  false  "Режим импорта"  Enums(atomic, best_effort)  default(atomic)
+// @Param        format   query     string  false  "Формат файла"  Enums(csv, ndjson)
+// @Param        file     formData  file    false  "Файл импорта"
+// @Success      200      {object}  Response{data=ImportResponse}
+// @Failure      400      {object}  Response
+// @Failure      401      {object}  Response
+// @Failure      403      {object}  Response
+// @Failure      413      {object}  Response
+// @Failure      415      {object}  Response
+// @Failure      429      {object}  Response
+// @Failure      500      {object}  Response
+// @Security     BearerAuth
+// @Router       /users/import [post]
+func (h *Handler) ImportUsers(c *gin.Context) {
+	var request ImportUsersRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		_ = c.Error(fmt.Errorf("%w: %v", errInvalidQuery, err))
+		return
+	}
+	if err := validate.Struct(request); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if request.Mode == "" {
+		request.Mode = ImportModeAtomic
+	}
+
+	body, format, err := importSource(c, request.Format)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	defer body.Close()
+
+	var rows []service.ImportRow
+	switch format {
+	case ImportFormatCSV:
+		rows, err = parseCSVImport(body, h.config.maxImportRows())
+	case ImportFormatNDJSON:
+		rows, err = parseNDJSONImport(body, h.config.maxImportRows())
+	}
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	report, err := h.userService.ImportUsers(c.Request.Context(), rows, service.ImportOptions{
+		DryRun: request.DryRun,
+		Atomic: request.Mode == ImportModeAtomic,
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	writeSuccessResponse(c, http.StatusOK, newImportResponse(request, report, requestTranslator(c)))
+}
+
+// importSource возвращает файл импорта и его формат
+func importSource(c *gin.Context, format string) (io.ReadCloser, string, error) {
+	body, name := c.Request.Body, ""
+	contentType, _, _ := mime.ParseMediaType(c.ContentType())
+	if contentType == "multipart/form-data" {
+		file, header, err := c.Request.FormFile(importFileField)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: %v", errMalformedRequest, err)
+		}
+		body, name = file, header.Filename
+		contentType, _, _ = mime.ParseMediaType(header.Header.Get("Content-Type"))
+	}
+
+	if format == "" {
+		format = importFormats[contentType]
+	}
+	if format == "" {
+		format = importFormats[strings.ToLower(filepath.Ext(name))]
+	}
+	if format == "" {
+		_ = body.Close()
+		return nil, "", errUnsupportedFormat
+	}
+	return body, format, nil
+}
+
+// parseCSVImport разбирает CSV со строкой заголовков. Столбцы ищутся по имени
+// без учёта регистра, неизвестные столбцы игнорируются.
+func parseCSVImport(r io.Reader, maxRows int) ([]service.ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: csv header: %v", errMalformedRequest, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Таблицы, сохранённые в UTF-8, часто начинаются с BOM
+		name = strings.TrimPrefix(name, "\ufeff")
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, fmt.Errorf("%w: csv header has no email column", errMalformedRequest)
+	}
+
+	var rows []service.ImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		// FieldPos можно вызывать только после записи, прочитанной без ошибки разбора
+		var line int
+		var parseErr *csv.ParseError
+		switch {
+		case err == nil:
+			line, _ = reader.FieldPos(0)
+		case errors.As(err, &parseErr) && errors.Is(err, csv.ErrFieldCount):
+			line = parseErr.StartLine
+		default:
+			return nil, fmt.Errorf("%w: %v", errMalformedRequest, err)
+		}
+		if len(rows) == maxRows {
+			return nil, errImportTooLarge
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		request := CreateUserRequest{
+			Name:     field("name"),
+			Email:    field("email"),
+			Password: field("password"),
+			Role:     domain.Role(field("role")),
+		}
+		var rowErr error
+		if err != nil {
+			rowErr = fmt.Errorf("%w: %v", errMalformedRequest, err)
+		} else if age := field("age"); age != "" {
+			v, err := strconv.ParseUint(age, 10, 32)
+			if err != nil {
+				rowErr = fmt.Errorf("%w: age: %v", errMalformedRequest, err)
+			}
+			request.Age = uint(v)
+		}
+		rows = append(rows, importRow(line, request, rowErr))
+	}
+	return rows, nil
+}
+
+// parseNDJSONImport разбирает JSON-объекты CreateUserRequest, по одному на строку;
+// пустые строки пропускаются
+func parseNDJSONImport(r io.Reader, maxRows int) ([]service.ImportRow, error) {
+	scanner := bufio.NewScanner(r)
+	var rows []service.ImportRow
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if len(rows) == maxRows {
+			return nil, errImportTooLarge
+		}
+
+		var request CreateUserRequest
+		var rowErr error
+		if err := json.Unmarshal(data, &request); err != nil {
+			rowErr = fmt.Errorf("%w: %v", errMalformedRequest, err)
+		}
+		rows = append(rows, importRow(line, request, rowErr))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", errMalformedRequest, err)
+	}
+	return rows, nil
+}
+
+// importRow проверяет строку так же, как тело POST /user
+func importRow(line int, request CreateUserRequest, err error) service.ImportRow {
+	if err == nil {
+		err = validate.Struct(request)
+	}
+	return service.ImportRow{
+		Line:     line,
+		Name:     request.Name,
+		Email:    request.Email,
+		Age:      request.Age,
+		Password: request.Password,
+		Role:     request.Role,
+		Err:      err,
+	}
+}
+
+func newImportResponse(request ImportUsersRequest, report *service.ImportReport, t translator) ImportResponse {
+	response := ImportResponse{
+		DryRun:    request.DryRun,
+		Mode:      request.Mode,
+		Committed: report.Committed,
+		Created:   report.Count(service.ImportCreated),
+		Valid:     report.Count(service.ImportValid),
+		Skipped:   report.Count(service.ImportSkipped),
+		Invalid:   report.Count(service.ImportInvalid),
+		Failed:    report.Count(service.ImportFailed),
+		Rows:      make([]ImportRowResponse, 0, len(report.Rows)),
+	}
+	for _, row := range report.Rows {
+		r := ImportRowResponse{Line: row.Line, Email: row.Email, Status: row.Status, UserID: row.UserID}
+		if row.Err != nil {
+			e := mapError(row.Err, t)
+			r.Error = &Error{Code: e.Code, Message: e.Message, Fields: e.Fields}
+		}
+		response.Rows = append(response.Rows, r)
+	}
+	return response
+}
+
+func (c Config) maxImportRows() int {
+	if c.MaxImportRows > 0 {
+		return c.MaxImportRows
+	}
+	return MaxImportRows
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"api_server/internal/service"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func importUsers(t *testing.T, query, contentType, body string) ImportResponse {
+	t.Helper()
+	r := newTestRouter()
+	r.POST("/users/import", handler.ImportUsers)
+
+	req, err := http.NewRequest(http.MethodPost, "/users/import"+query, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	var response ImportResponse
+	if err := decodeData(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range response.Rows {
+		if row.UserID != 0 {
+			t.Cleanup(func() {
+				_ = svc.PurgeUser(context.Background(), row.UserID, 0)
+			})
+		}
+	}
+	return response
+}
+
+func importStatuses(response ImportResponse) []service.ImportStatus {
+	statuses := make([]service.ImportStatus, 0, len(response.Rows))
+	for _, row := range response.Rows {
+		statuses = append(statuses, row.Status)
+	}
+	return statuses
+}
+
+const importCSV = "\ufeffName,Email,Age,Password\n" +
+	"Import 1,import_1@example.com,30,test-password\n" +
+	"Import 2,import_2@example.com,abc,test-password\n" +
+	"Import 3,test_3@example.com,30,test-password\n" +
+	"Import 4,import_1@example.com,30,test-password\n" +
+	"Import 5,import_5@example.com,10,test-password\n"
+
+func TestHandler_ImportUsersCSV(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		committed bool
+		want      []service.ImportStatus
+	}{
+		{
+			name:  "dry run",
+			query: "?dry_run=true&mode=best_effort",
+			want: []service.ImportStatus{
+				service.ImportValid, service.ImportInvalid, service.ImportSkipped, service.ImportSkipped, service.ImportInvalid,
+			},
+		},
+		{
+			name:  "atomic with invalid rows",
+			query: "",
+			want: []service.ImportStatus{
+				service.ImportValid, service.ImportInvalid, service.ImportSkipped, service.ImportSkipped, service.ImportInvalid,
+			},
+		},
+		{
+			name:      "best effort",
+			query:     "?mode=best_effort",
+			committed: true,
+			want: []service.ImportStatus{
+				service.ImportCreated, service.ImportInvalid, service.ImportSkipped, service.ImportSkipped, service.ImportInvalid,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := importUsers(t, tt.query, "text/csv", importCSV)
+			if response.Committed != tt.committed {
+				t.Errorf("committed = %v, want %v", response.Committed, tt.committed)
+			}
+			if got := importStatuses(response); !slices.Equal(got, tt.want) {
+				t.Errorf("statuses = %v, want %v", got, tt.want)
+			}
+			if response.Rows[0].Line != 2 || response.Rows[4].Line != 6 {
+				t.Errorf("unexpected line numbers: %+v", response.Rows)
+			}
+			if e := response.Rows[2].Error; e == nil || e.Code != CodeEmailTaken {
+				t.Errorf("duplicate row error = %+v, want %s", e, CodeEmailTaken)
+			}
+			if e := response.Rows[4].Error; e == nil || e.Code != CodeValidationFailed {
+				t.Errorf("invalid row error = %+v, want %s", e, CodeValidationFailed)
+			}
+		})
+	}
+}
+
+func TestHandler_ImportUsersNDJSONAtomic(t *testing.T) {
+	body := `{"name": "Import 6", "email": "import_6@example.com", "age": 30, "password": "test-password"}
+
+{"name": "Import 7", "email": "import_7@example.com", "age": 40, "password": "test-password"}
+`
+	response := importUsers(t, "", "application/x-ndjson", body)
+	if !response.Committed || response.Created != 2 {
+		t.Fatalf("unexpected import result: %+v", response)
+	}
+	if response.Rows[1].Line != 3 {
+		t.Errorf("line = %d, want 3", response.Rows[1].Line)
+	}
+	user, err := svc.GetUserByEmail(t.Context(), "import_7@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != response.Rows[1].UserID || user.Age != 40 {
+		t.Errorf("unexpected imported user: %+v", user)
+	}
+}
+
+func TestHandler_ImportUsersPasswordTooLong(t *testing.T) {
+	// 42 символа, но 84 байта: bcrypt такой пароль не примет
+	password := strings.Repeat("пароль", 7)
+	body := `{"name": "Import 8", "email": "import_8@example.com", "age": 30, "password": "test-password"}
+{"name": "Import 9", "email": "import_9@example.com", "age": 30, "password": "` + password + `"}
+`
+	response := importUsers(t, "?mode=best_effort", "application/x-ndjson", body)
+	want := []service.ImportStatus{service.ImportCreated, service.ImportInvalid}
+	if got := importStatuses(response); !response.Committed || !slices.Equal(got, want) {
+		t.Fatalf("statuses = %v, want %v: %+v", got, want, response)
+	}
+	if e := response.Rows[1].Error; e == nil || e.Code != CodeValidationFailed || len(e.Fields) != 1 || e.Fields[0].Field != "password" {
+		t.Errorf("invalid row error = %+v, want %s for password", e, CodeValidationFailed)
+	}
+}
+
+func TestHandler_ImportUsersUnsupportedFormat(t *testing.T) {
+	r := newTestRouter()
+	r.POST("/users/import", handler.ImportUsers)
+
+	req, err := http.NewRequest(http.MethodPost, "/users/import", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusUnsupportedMediaType)
+	}
+}
+
+func TestHandler_ImportUsersMalformedCSV(t *testing.T) {
+	r := newTestRouter()
+	r.POST("/users/import", handler.ImportUsers)
+
+	// Ошибка кавычек в первом столбце
+	req, err := http.NewRequest(http.MethodPost, "/users/import", strings.NewReader("email,name\n\"a\"b@x.com,A\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", w.Code, http.StatusBadRequest, w.Body.String())
+	}
+	var response Response
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error == nil || response.Error.Code != CodeMalformedRequest {
+		t.Errorf("handler returned unexpected body: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"api_server/internal/metrics"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+const (
+	// unmatchedRoute — метка маршрута для запросов, не попавших ни в один маршрут
+	unmatchedRoute = "unmatched"
+	// otherMethod — метка метода для нестандартных методов: клиент не должен
+	// плодить новые серии метрик произвольными методами
+	otherMethod = "OTHER"
+)
+
+// Metrics учитывает каждый запрос в метриках m по шаблону маршрута и статусу.
+// Должен стоять перед ErrorHandler и Recovery, чтобы видеть итоговый статус ответа.
+// Запрос, оборванный паникой http.ErrAbortHandler, тоже учитывается.
+func Metrics(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		m.RequestStarted()
+		defer func() {
+			route := c.FullPath()
+			if route == "" {
+				route = unmatchedRoute
+			}
+			m.RequestFinished(methodLabel(c.Request.Method), route, c.Writer.Status(), time.Since(start))
+		}()
+		c.Next()
+	}
+}
+
+// methodLabel возвращает метку метода запроса для метрик
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return otherMethod
+	}
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"api_server/internal/domain"
+	"api_server/internal/metrics"
+	"api_server/internal/repository/memory"
+	"api_server/internal/service"
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	m := metrics.New()
+	s := service.NewUserService(repo, memory.NewMockMemoryUserHistoryRepository(), policy, audit, memory.NewMockMemoryTransactor(), nil, nil, m)
+	if err := m.RegisterUsersTotal(func(context.Context) (int64, error) { return 3, nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := svc.CreateUser(t.Context(), "Metrics", "metrics@example.com", 30, testPassword, domain.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = svc.PurgeUser(context.Background(), user.ID, 0)
+	})
+
+	r := gin.New()
+	r.Use(Metrics(m), ErrorHandler())
+	r.GET("/user/:id", (&Handler{userService: s}).GetUser)
+	r.GET("/metrics", gin.WrapH(m.Handler()))
+
+	for _, path := range []string{fmt.Sprintf("/user/%d", user.ID), "/user/999999", "/missing"} {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	req, err := http.NewRequest("PROPFIND-42", "/missing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	req, err = http.NewRequest(http.MethodGet, "/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+
+	body := w.Body.String()
+	for _, want := range []string{
+		`api_http_requests_total{method="GET",route="/user/:id",status="200"} 1`,
+		`api_http_requests_total{method="GET",route="/user/:id",status="404"} 1`,
+		`api_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`api_http_requests_total{method="OTHER",route="unmatched",status="404"} 1`,
+		`api_http_request_duration_seconds_count{method="GET",route="/user/:id",status="200"} 1`,
+		// Сам запрос к /metrics ещё выполняется
+		`api_http_requests_in_flight 1`,
+		`api_user_service_operations_total{operation="get_user"} 2`,
+		`api_user_service_errors_total{operation="get_user",type="not_found"} 1`,
+		`api_users_total 3`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics have no %q", want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"api_server/internal/requestctx"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	requestIDKey    = "request_id"
+	// maxRequestIDLength совпадает с размером колонки request_id в журнале аудита
+	maxRequestIDLength = 64
+)
+
+// Timeout ограничивает время обработки запроса: контекст запроса отменяется
+// по истечении d, и вместе с ним прерываются запросы к БД. Для маршрутов
+// из routes (ключ — шаблон маршрута, см. gin.Context.FullPath) действует
+// своё ограничение: вложенный Timeout не смог бы продлить общее.
+func Timeout(d time.Duration, routes map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout := d
+		if routeTimeout, ok := routes[c.FullPath()]; ok {
+			timeout = routeTimeout
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// RequestID принимает идентификатор запроса из заголовка X-Request-ID
+// или генерирует новый и возвращает его в ответе. Идентификатор и IP клиента
+// также сохраняются в контексте запроса (см. requestctx.From).
+// Слишком длинный идентификатор или идентификатор с посторонними символами
+// заменяется новым: он попадает в логи, заголовки ответа и журнал аудита.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(requestctx.With(c.Request.Context(), requestctx.Info{
+			ID: id,
+			IP: c.ClientIP(),
+		}))
+		c.Next()
+	}
+}
+
+// Logger пишет в logger запись о каждом запросе: метод, шаблон маршрута, статус,
+// длительность, размер ответа и IP клиента. ID запроса и субъект добавляет
+// обработчик логгера из контекста (см. logging.NewHandler), поэтому Logger
+// должен стоять после RequestID, но перед ErrorHandler, чтобы видеть итоговый статус.
+// Запрос, оборванный паникой (http.ErrAbortHandler), записывается с aborted=true,
+// после чего паника идёт дальше.
+func Logger(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		defer func() {
+			aborted := recover()
+
+			status := c.Writer.Status()
+			attrs := []slog.Attr{
+				slog.String("method", c.Request.Method),
+				slog.String("route", c.FullPath()),
+				slog.String("path", c.Request.URL.Path),
+				slog.Int("status", status),
+				slog.Duration("latency", time.Since(start)),
+				slog.Int("bytes", max(c.Writer.Size(), 0)),
+				slog.String("client_ip", c.ClientIP()),
+			}
+			if len(c.Errors) > 0 {
+				attrs = append(attrs, slog.String("error", c.Errors.Last().Error()))
+			}
+
+			level := slog.LevelInfo
+			switch {
+			case aborted != nil:
+				attrs = append(attrs, slog.Bool("aborted", true))
+				level = slog.LevelError
+			case status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case status >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			}
+			logger.LogAttrs(c.Request.Context(), level, "http request", attrs...)
+
+			if aborted != nil {
+				panic(aborted)
+			}
+		}()
+		c.Next()
+	}
+}
+
+// Recovery отвечает 500 на панику обработчика и пишет её в лог вместе со стеком.
+// http.ErrAbortHandler пропускается дальше: сервер молча обрывает соединение.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err any) {
+		if err == http.ErrAbortHandler {
+			panic(err)
+		}
+		slog.ErrorContext(c.Request.Context(), "panic recovered",
+			slog.Any("error", err),
+			slog.String("stack", string(debug.Stack())),
+		)
+		_ = c.Error(fmt.Errorf("panic: %v", err))
+		c.Abort()
+		flushError(c)
+	})
+}
+
+// validRequestID допускает непустой идентификатор до maxRequestIDLength
+// символов из латинских букв, цифр и знаков "-", "_", ".", ":"
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ErrorHandler превращает ошибки, добавленные обработчиком через c.Error,
+// в ответ API. Статус и код ошибки определяются в mapError, поэтому
+// обработчикам достаточно вызвать c.Error(err) и завершиться.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		flushError(c)
+	}
+}
+
+// flushError записывает последнюю ошибку из c.Errors, если ответ ещё не записан
+func flushError(c *gin.Context) {
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+	writeError(c, c.Errors.Last().Err)
+}
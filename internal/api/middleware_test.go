@@ -0,0 +1,137 @@
+package api
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/logging"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelDebug)
+
+	r := gin.New()
+	r.Use(RequestID(), Logger(logger), ErrorHandler())
+	r.GET("/items/:id", func(c *gin.Context) {
+		setPrincipal(c, auth.Principal{UserID: 7})
+		// Так пишут в лог сервисы и репозитории: с контекстом запроса
+		logger.InfoContext(c.Request.Context(), "inside handler")
+		c.String(http.StatusOK, "ok")
+	})
+
+	req, err := http.NewRequest(http.MethodGet, "/items/42", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(RequestIDHeader, "incident-42")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get(RequestIDHeader); got != "incident-42" {
+		t.Errorf("%s = %q, want incident-42", RequestIDHeader, got)
+	}
+
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("log line is not JSON: %s", scanner.Text())
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d log entries, want 2: %s", len(entries), buf.String())
+	}
+	for _, entry := range entries {
+		if entry["request_id"] != "incident-42" || entry["user_id"] != float64(7) {
+			t.Errorf("entry is not correlated with the request: %v", entry)
+		}
+	}
+
+	want := map[string]interface{}{
+		"msg":    "http request",
+		"method": http.MethodGet,
+		"route":  "/items/:id",
+		"path":   "/items/42",
+		"status": float64(http.StatusOK),
+		"bytes":  float64(2),
+	}
+	for key, value := range want {
+		if entries[1][key] != value {
+			t.Errorf("request entry %s = %v, want %v", key, entries[1][key], value)
+		}
+	}
+	if _, ok := entries[1]["latency"]; !ok {
+		t.Error("request entry has no latency")
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	r := gin.New()
+	r.Use(RequestID())
+	r.GET("/ping", handler.Ping)
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{name: "valid", header: "req_1.2:abc-DEF", keep: true},
+		{name: "empty", header: ""},
+		{name: "too long", header: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "log injection", header: "id\n{\"level\":\"ERROR\"}"},
+		{name: "spaces", header: "id with spaces"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/ping", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set(RequestIDHeader, tt.header)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			got := w.Header().Get(RequestIDHeader)
+			if tt.keep && got != tt.header {
+				t.Errorf("%s = %q, want %q", RequestIDHeader, got, tt.header)
+			}
+			if !tt.keep && (got == tt.header || !validRequestID(got)) {
+				t.Errorf("%s = %q, want a generated ID", RequestIDHeader, got)
+			}
+		})
+	}
+}
+
+func TestRecovery_AbortHandler(t *testing.T) {
+	r := gin.New()
+	r.Use(Recovery(), ErrorHandler())
+	r.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	r.GET("/abort", func(c *gin.Context) {
+		panic(http.ErrAbortHandler)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("panic: got status %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+
+	// Обрыв соединения должен дойти до http.Server, а не превратиться в ответ 500
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", err)
+		}
+	}()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abort", nil))
+}
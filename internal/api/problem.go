@@ -0,0 +1,57 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+const (
+	ProblemContentType = "application/problem+json"
+	problemDetailsKey  = "problem_details"
+)
+
+// Problem — описание ошибки в формате RFC 7807 (application/problem+json)
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Расширения RFC 7807
+	Code      ErrorCode    `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// ProblemDetails включает ответы об ошибках в формате application/problem+json
+// для всех запросов. Без него формат выбирается по заголовку Accept.
+func ProblemDetails() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(problemDetailsKey, true)
+		c.Next()
+	}
+}
+
+func wantsProblem(c *gin.Context) bool {
+	return c.GetBool(problemDetailsKey) || strings.Contains(c.GetHeader("Accept"), ProblemContentType)
+}
+
+func newProblem(c *gin.Context, e apiError) Problem {
+	return Problem{
+		Type:      problemType(e.Code),
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Detail:    e.Message,
+		Instance:  c.Request.URL.RequestURI(),
+		Code:      e.Code,
+		RequestID: c.GetString(requestIDKey),
+		Errors:    e.Fields,
+	}
+}
+
+// problemType строит относительный URI типа проблемы из кода ошибки:
+// USER_NOT_FOUND -> /problems/user-not-found
+func problemType(code ErrorCode) string {
+	return "/problems/" + strings.ToLower(strings.ReplaceAll(string(code), "_", "-"))
+}
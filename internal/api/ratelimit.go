@@ -0,0 +1,84 @@
+package api
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/ratelimit"
+	"api_server/internal/service"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+	RateLimitPolicyHeader    = "RateLimit-Policy"
+	RetryAfterHeader         = "Retry-After"
+)
+
+// RateLimitKey возвращает ключ корзины, по которой считается запрос
+type RateLimitKey func(c *gin.Context) string
+
+// GlobalKey — одна корзина на все запросы
+func GlobalKey(*gin.Context) string {
+	return "global"
+}
+
+// IPKey — корзина на IP клиента
+func IPKey(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// IdentityKey — корзина на пользователя или API-ключ; запросы без
+// аутентификации считаются по IP. Должен стоять после Authenticate.
+func IdentityKey(c *gin.Context) string {
+	if _, ok := auth.PrincipalFromContext(c.Request.Context()); !ok {
+		return IPKey(c)
+	}
+	return service.Actor(c.Request.Context())
+}
+
+// RateLimit пропускает не больше limit запросов с одним ключом key; name отделяет
+// корзины разных ограничений. Ответ получает заголовки RateLimit-*, а превысивший
+// ограничение запрос — 429 с Retry-After. Если несколько ограничений стоят друг
+// за другом, в заголовках остаётся самое строгое. Нулевой limit ничего не ограничивает.
+func RateLimit(store ratelimit.Store, name string, limit ratelimit.Limit, key RateLimitKey) gin.HandlerFunc {
+	if limit.IsZero() {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	policy := fmt.Sprintf("%d;w=%d", limit.Requests, ceilSeconds(limit.Period))
+
+	return func(c *gin.Context) {
+		result, err := store.Take(c.Request.Context(), name+":"+key(c), limit)
+		if err != nil {
+			// Недоступное хранилище не должно останавливать весь сервис
+			slog.ErrorContext(c.Request.Context(), "rate limit store", "limit", name, "error", err)
+			c.Next()
+			return
+		}
+
+		if current, err := strconv.Atoi(c.Writer.Header().Get(RateLimitRemainingHeader)); err != nil || result.Remaining < current {
+			c.Header(RateLimitLimitHeader, strconv.Itoa(result.Limit))
+			c.Header(RateLimitRemainingHeader, strconv.Itoa(result.Remaining))
+			c.Header(RateLimitResetHeader, strconv.Itoa(ceilSeconds(result.Reset)))
+			c.Header(RateLimitPolicyHeader, policy)
+		}
+		if !result.Allowed {
+			c.Header(RetryAfterHeader, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			_ = c.Error(errRateLimited)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/ratelimit"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	r := newTestRouter()
+	r.Use(
+		RateLimit(store, "global", ratelimit.Limit{Requests: 100, Period: time.Minute}, GlobalKey),
+		RateLimit(store, "ip", ratelimit.Limit{Requests: 2, Period: time.Hour}, IPKey),
+	)
+	r.GET("/ping", handler.Ping)
+
+	ping := func(remoteAddr string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, "/ping", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i, wantRemaining := range []string{"1", "0"} {
+		w := ping("10.0.0.1:1234")
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: got status %v want %v", i, w.Code, http.StatusOK)
+		}
+		// В заголовках — самое строгое из ограничений
+		if got := w.Header().Get(RateLimitLimitHeader); got != "2" {
+			t.Errorf("request %d: %s = %q, want 2", i, RateLimitLimitHeader, got)
+		}
+		if got := w.Header().Get(RateLimitRemainingHeader); got != wantRemaining {
+			t.Errorf("request %d: %s = %q, want %s", i, RateLimitRemainingHeader, got, wantRemaining)
+		}
+		if got := w.Header().Get(RateLimitPolicyHeader); got != "2;w=3600" {
+			t.Errorf("request %d: %s = %q, want 2;w=3600", i, RateLimitPolicyHeader, got)
+		}
+	}
+
+	w := ping("10.0.0.1:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("got status %v want %v", w.Code, http.StatusTooManyRequests)
+	}
+	if retryAfter, err := strconv.Atoi(w.Header().Get(RetryAfterHeader)); err != nil || retryAfter <= 0 || retryAfter > 1800 {
+		t.Errorf("%s = %q, want up to 1800 seconds", RetryAfterHeader, w.Header().Get(RetryAfterHeader))
+	}
+	var response Response
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error == nil || response.Error.Code != CodeRateLimited {
+		t.Errorf("handler returned unexpected body: %s", w.Body.String())
+	}
+
+	// У другого IP своя корзина
+	if w := ping("10.0.0.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("another IP: got status %v want %v", w.Code, http.StatusOK)
+	}
+}
+
+func TestRateLimit_ForwardedFor(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	r := newTestRouter()
+	r.Use(RateLimit(store, "ip", ratelimit.Limit{Requests: 1, Period: time.Hour}, IPKey))
+	r.GET("/ping", handler.Ping)
+
+	ping := func(forwardedFor string) int {
+		req, err := http.NewRequest(http.MethodGet, "/ping", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = "10.0.1.1:1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Без доверенных прокси подменённый заголовок не даёт новой корзины
+	if err := r.SetTrustedProxies(nil); err != nil {
+		t.Fatal(err)
+	}
+	if code := ping("192.0.2.1"); code != http.StatusOK {
+		t.Fatalf("got status %v want %v", code, http.StatusOK)
+	}
+	if code := ping("192.0.2.2"); code != http.StatusTooManyRequests {
+		t.Errorf("spoofed X-Forwarded-For: got status %v want %v", code, http.StatusTooManyRequests)
+	}
+
+	// За доверенным прокси корзина у каждого клиента своя
+	if err := r.SetTrustedProxies([]string{"10.0.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if code := ping("192.0.2.3"); code != http.StatusOK {
+		t.Errorf("trusted proxy: got status %v want %v", code, http.StatusOK)
+	}
+}
+
+func TestRateLimit_ProblemDetails(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	// Порядок как в main.go: ProblemDetails до ErrorHandler и глобального ограничения
+	r := gin.New()
+	r.Use(ProblemDetails(), ErrorHandler(), RateLimit(store, "global", ratelimit.Limit{Requests: 1, Period: time.Hour}, GlobalKey))
+	r.GET("/ping", handler.Ping)
+
+	var w *httptest.ResponseRecorder
+	for range 2 {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("got status %v want %v", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Content-Type"); got != ProblemContentType {
+		t.Errorf("Content-Type = %q, want %s", got, ProblemContentType)
+	}
+	var problem Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Status != http.StatusTooManyRequests || problem.Code != CodeRateLimited {
+		t.Errorf("handler returned unexpected problem: %s", w.Body.String())
+	}
+}
+
+func TestIdentityKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		want      string
+	}{
+		{"anonymous", nil, "ip:192.0.2.1"},
+		{"user", &auth.Principal{UserID: 7}, "user:7"},
+		{"api key", &auth.Principal{APIKeyID: 3, Scopes: []string{auth.ScopeUsersRead}}, "api-key:3"},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/ping", nil)
+		c.Request.RemoteAddr = "192.0.2.1:1234"
+		if tt.principal != nil {
+			setPrincipal(c, *tt.principal)
+		}
+		if got := IdentityKey(c); got != tt.want {
+			t.Errorf("%s: IdentityKey = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimit_IdentityKey(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	r := newTestRouter()
+	r.Use(
+		func(c *gin.Context) {
+			id, _ := strconv.Atoi(c.Query("user"))
+			setPrincipal(c, auth.Principal{UserID: uint(id)})
+		},
+		RateLimit(store, "user", ratelimit.Limit{Requests: 1, Period: time.Hour}, IdentityKey),
+	)
+	r.GET("/ping", handler.Ping)
+
+	// Все запросы с одного IP, но у каждого пользователя своя корзина
+	ping := func(user int) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping?user="+strconv.Itoa(user), nil))
+		return w.Code
+	}
+	for _, tt := range []struct {
+		user     int
+		wantCode int
+	}{
+		{1, http.StatusOK},
+		{2, http.StatusOK},
+		{1, http.StatusTooManyRequests},
+	} {
+		if code := ping(tt.user); code != tt.wantCode {
+			t.Errorf("user %d: got status %v want %v", tt.user, code, tt.wantCode)
+		}
+	}
+}
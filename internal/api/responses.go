@@ -1,32 +1,57 @@
 package api
 
 import (
-	"encoding/json"
-	"net/http"
+	"github.com/gin-gonic/gin"
+	"time"
 )
 
-func writeErrorResponse(
-	w http.ResponseWriter,
-	data string,
-	code int,
-) {
-	w.WriteHeader(code)
-	response := Response{
+// writeError отвечает ошибкой, сопоставленной с HTTP-статусом через mapError
+func writeError(c *gin.Context, err error) {
+	writeAPIError(c, mapError(err, requestTranslator(c)))
+}
+
+func writeAPIError(c *gin.Context, e apiError) {
+	if wantsProblem(c) {
+		c.Header("Content-Type", ProblemContentType)
+		c.JSON(e.Status, newProblem(c, e))
+		return
+	}
+
+	c.JSON(e.Status, Response{
 		Status: StatusError,
-		Code:   code,
+		Code:   e.Status,
+		Error: &Error{
+			Code:    e.Code,
+			Message: e.Message,
+			Fields:  e.Fields,
+		},
+		Meta: newMeta(c),
+	})
+}
+
+func writeSuccessResponse(c *gin.Context, httpCode int, data interface{}) {
+	c.JSON(httpCode, Response{
+		Status: StatusSuccess,
+		Code:   httpCode,
 		Data:   data,
-	}
-	responseJSON, _ := json.Marshal(response)
-	w.Write(responseJSON)
+		Meta:   newMeta(c),
+	})
 }
 
-func writeSuccessResponse(w http.ResponseWriter, data string, code int) {
-	w.WriteHeader(code)
-	response := Response{
+func writeListResponse(c *gin.Context, httpCode int, data interface{}, pagination Pagination) {
+	meta := newMeta(c)
+	meta.Pagination = &pagination
+	c.JSON(httpCode, Response{
 		Status: StatusSuccess,
-		Code:   code,
+		Code:   httpCode,
 		Data:   data,
+		Meta:   meta,
+	})
+}
+
+func newMeta(c *gin.Context) Meta {
+	return Meta{
+		RequestID: c.GetString(requestIDKey),
+		Timestamp: time.Now().UTC(),
 	}
-	responseJSON, _ := json.Marshal(response)
-	w.Write(responseJSON)
 }
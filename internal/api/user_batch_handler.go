@@ -0,0 +1,200 @@
+package api
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/domain"
+	"api_server/internal/service"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	// MaxBatchSize — максимальное число операций в пакете по умолчанию
+	MaxBatchSize = 100
+
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+
+	BatchModeAtomic      = "atomic"
+	BatchModeIndependent = "independent"
+)
+
+type BatchOperation struct {
+	Op string `json:"op" validate:"required,oneof=create update delete" enums:"create,update,delete"`
+	// ID — пользователь для update и delete
+	ID uint `json:"id"`
+	// Version — как If-Match: операция выполняется, только если версия пользователя не изменилась
+	Version uint `json:"version"`
+	// Hard — удалить безвозвратно (для delete)
+	Hard bool `json:"hard"`
+	// Data — тело запроса: CreateUserRequest для create, UpdateUserRequest для update
+	Data json.RawMessage `json:"data" swaggertype:"object"`
+}
+
+type BatchRequest struct {
+	Mode       string           `json:"mode" validate:"omitempty,oneof=atomic independent" enums:"atomic,independent"`
+	Operations []BatchOperation `json:"operations" validate:"required,min=1,dive"`
+}
+
+type BatchResult struct {
+	Index int `json:"index"`
+	// Status — HTTP-статус, который вернул бы одиночный запрос
+	Status int          `json:"status"`
+	Data   *domain.User `json:"data,omitempty"`
+	Error  *Error       `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Mode string `json:"mode"`
+	// Committed сообщает, были ли записаны изменения; в режиме independent —
+	// хотя бы одной операции
+	Committed bool          `json:"committed"`
+	Results   []BatchResult `json:"results"`
+}
+
+// BatchUsers godoc
+// @Summary      Пакет операций с пользователями
+// @Description  Операции create, update и delete выполняются по порядку с той же семантикой, что POST /user,
+// @Description  PATCH /user/{id} и DELETE /user/{id}. В режиме atomic (по умолчанию) все операции выполняются
+// @Description  в одной транзакции: после первой ошибки изменения откатываются, а остальные операции получают
+// @Description  статус 424. В режиме independent каждая операция выполняется независимо от других.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body      BatchRequest  true  "JSON"
+// @Success      200      {object}  Response{data=BatchResponse}
+// @Failure      400      {object}  Response
+// @Failure      401      {object}  Response
+// @Failure      403      {object}  Response
+// @Failure      413      {object}  Response
+// @Failure      429      {object}  Response
+// @Failure      500      {object}  Response
+// @Security     BearerAuth
+// @Router       /users/batch [post]
+func (h *Handler) BatchUsers(c *gin.Context) {
+	var request BatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(fmt.Errorf("%w: %v", errMalformedRequest, err))
+		return
+	}
+	if err := validate.Struct(request); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if len(request.Operations) > h.config.maxBatchSize() {
+		_ = c.Error(errBatchTooLarge)
+		return
+	}
+	if request.Mode == "" {
+		request.Mode = BatchModeAtomic
+	}
+
+	t := requestTranslator(c)
+	response := BatchResponse{Mode: request.Mode, Results: make([]BatchResult, len(request.Operations))}
+	run := func(ctx context.Context, i int) error {
+		user, status, err := h.batchOperation(ctx, request.Operations[i])
+		response.Results[i] = BatchResult{Index: i, Status: status, Data: user}
+		if err != nil {
+			e := mapError(err, t)
+			// В ответе остаётся только код ошибки, поэтому её причину нужно записать здесь:
+			// ErrorHandler и Logger видят лишь успешный ответ на весь пакет
+			if e.Status >= http.StatusInternalServerError {
+				slog.ErrorContext(ctx, "batch operation", "index", i, "op", request.Operations[i].Op, "error", err)
+			}
+			response.Results[i].Status = e.Status
+			response.Results[i].Error = &Error{Code: e.Code, Message: e.Message, Fields: e.Fields}
+		}
+		return err
+	}
+
+	if request.Mode == BatchModeIndependent {
+		for i := range request.Operations {
+			if err := run(c.Request.Context(), i); err == nil {
+				response.Committed = true
+			}
+		}
+		writeSuccessResponse(c, http.StatusOK, response)
+		return
+	}
+
+	failed := -1
+	err := h.userService.Transaction(c.Request.Context(), func(ctx context.Context) error {
+		for i := range request.Operations {
+			if err := run(ctx, i); err != nil {
+				failed = i
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && failed < 0 {
+		// Не удалось зафиксировать транзакцию
+		_ = c.Error(err)
+		return
+	}
+	if err != nil {
+		aborted := mapError(errBatchAborted, t)
+		for i := range response.Results {
+			if i != failed {
+				response.Results[i] = BatchResult{
+					Index:  i,
+					Status: aborted.Status,
+					Error:  &Error{Code: aborted.Code, Message: aborted.Message},
+				}
+			}
+		}
+	}
+	response.Committed = err == nil
+	writeSuccessResponse(c, http.StatusOK, response)
+}
+
+// batchOperation выполняет одну операцию пакета и возвращает статус успешного ответа
+func (h *Handler) batchOperation(ctx context.Context, op BatchOperation) (*domain.User, int, error) {
+	switch op.Op {
+	case BatchOpCreate:
+		var request CreateUserRequest
+		if err := decodeBatchData(op.Data, &request); err != nil {
+			return nil, 0, err
+		}
+		user, err := h.createUser(ctx, request)
+		return user, http.StatusCreated, err
+	case BatchOpUpdate:
+		if op.ID == 0 {
+			return nil, 0, service.ErrIDNotTransmitted
+		}
+		var request UpdateUserRequest
+		if err := decodeBatchData(op.Data, &request); err != nil {
+			return nil, 0, err
+		}
+		user, err := h.updateUser(ctx, op.ID, op.Version, request)
+		return user, http.StatusOK, err
+	default:
+		if op.ID == 0 {
+			return nil, 0, service.ErrIDNotTransmitted
+		}
+		// Маршрут пакета требует только users:write, а удаление — ещё и users:delete
+		if principal, ok := auth.PrincipalFromContext(ctx); ok && !principal.HasScope(auth.ScopeUsersDelete) {
+			return nil, 0, insufficientScope(auth.ScopeUsersDelete)
+		}
+		return nil, http.StatusOK, h.deleteUser(ctx, op.ID, op.Version, op.Hard)
+	}
+}
+
+func decodeBatchData(data json.RawMessage, v interface{}) error {
+	if len(data) == 0 {
+		return fmt.Errorf("%w: data is required", errMalformedRequest)
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (c Config) maxBatchSize() int {
+	if c.MaxBatchSize > 0 {
+		return c.MaxBatchSize
+	}
+	return MaxBatchSize
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func batchUsers(t *testing.T, body string) BatchResponse {
+	t.Helper()
+	r := newTestRouter()
+	r.POST("/users/batch", handler.BatchUsers)
+
+	req, err := http.NewRequest(http.MethodPost, "/users/batch", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	var response BatchResponse
+	if err := decodeData(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range response.Results {
+		if result.Status == http.StatusCreated && result.Data != nil {
+			t.Cleanup(func() {
+				_ = svc.PurgeUser(context.Background(), result.Data.ID, 0)
+			})
+		}
+	}
+	return response
+}
+
+func batchStatuses(response BatchResponse) []int {
+	statuses := make([]int, len(response.Results))
+	for i, result := range response.Results {
+		statuses[i] = result.Status
+	}
+	return statuses
+}
+
+func TestHandler_BatchUsersAtomicRollback(t *testing.T) {
+	before, err := svc.GetUserByID(t.Context(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := batchUsers(t, `{"operations": [
+		{"op": "update", "id": 3, "data": {"name": "Batch name"}},
+		{"op": "create", "data": {"name": "Batch 1", "email": "batch_1@example.com", "age": 30, "password": "test-password"}},
+		{"op": "create", "data": {"name": "Batch 2", "email": "batch_2@example.com", "age": 5, "password": "test-password"}}
+	]}`)
+	if response.Committed {
+		t.Error("batch with a failed operation was committed")
+	}
+	if got, want := batchStatuses(response), []int{http.StatusFailedDependency, http.StatusFailedDependency, http.StatusBadRequest}; !slices.Equal(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+	if e := response.Results[0].Error; e == nil || e.Code != CodeBatchAborted {
+		t.Errorf("aborted operation error = %+v, want %s", e, CodeBatchAborted)
+	}
+
+	after, err := svc.GetUserByID(t.Context(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Name != before.Name || after.Version != before.Version {
+		t.Errorf("update was not rolled back: got %+v want %+v", after, before)
+	}
+	if _, err := svc.GetUserByEmail(t.Context(), "batch_1@example.com"); err == nil {
+		t.Error("create was not rolled back")
+	}
+	svc.Wait()
+	if _, ok := outbox.Last("batch_1@example.com"); ok {
+		t.Error("verification email was sent for a rolled back create")
+	}
+}
+
+func TestHandler_BatchUsersIndependent(t *testing.T) {
+	response := batchUsers(t, `{"mode": "independent", "operations": [
+		{"op": "create", "data": {"name": "Batch 3", "email": "batch_3@example.com", "age": 30, "password": "test-password"}},
+		{"op": "update", "id": 9999, "data": {"name": "Nobody"}},
+		{"op": "delete", "id": 3, "version": 9999},
+		{"op": "delete"}
+	]}`)
+	if !response.Committed {
+		t.Error("batch with a successful operation was not committed")
+	}
+	want := []int{http.StatusCreated, http.StatusNotFound, http.StatusPreconditionFailed, http.StatusBadRequest}
+	if got := batchStatuses(response); !slices.Equal(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+	if user := response.Results[0].Data; user == nil || user.Email != "batch_3@example.com" {
+		t.Errorf("unexpected created user: %+v", user)
+	}
+}
+
+func TestHandler_BatchUsersIndependentInternalError(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	r := newTestRouter()
+	r.POST("/users/batch", handler.BatchUsers)
+	body := `{"mode": "independent", "operations": [
+		{"op": "update", "id": 3, "data": {"name": "Batch name"}}
+	]}`
+	// Запрос к БД с отменённым контекстом завершается внутренней ошибкой
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/users/batch", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var response BatchResponse
+	if err := decodeData(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if got := batchStatuses(response); len(got) != 1 || got[0] < http.StatusInternalServerError {
+		t.Fatalf("statuses = %v, want a 5xx status", got)
+	}
+	if !strings.Contains(logs.String(), `"msg":"batch operation"`) || !strings.Contains(logs.String(), context.Canceled.Error()) {
+		t.Errorf("internal error was not logged: %s", logs.String())
+	}
+}
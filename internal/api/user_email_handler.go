@@ -0,0 +1,77 @@
+package api
+
+import (
+	"api_server/internal/service"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type ChangeEmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// RequestEmailChange godoc
+// @Summary      Смена email
+// @Description  Отправляет на новый адрес ссылку для подтверждения; email меняется, только когда пользователь перейдёт по ней.
+// @Description  Запрос с текущим неподтверждённым адресом отправляет письмо для его подтверждения повторно.
+// @Description  Сменить email может только сам пользователь или администратор; API-ключам это запрещено.
+// @Tags         user
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int                 true "ID пользователя"
+// @Param        request  body      ChangeEmailRequest  true "Новый email"
+// @Success      202  {object}  Response
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      404  {object}  Response
+// @Failure      409  {object}  Response
+// @Failure      429  {object}  Response
+// @Failure      500  {object}  Response
+// @Security     BearerAuth
+// @Router       /user/{id}/email [post]
+func (h *Handler) RequestEmailChange(c *gin.Context) {
+	id, err := h.ParseUserId(c.Param("id"))
+	if err != nil {
+		_ = c.Error(service.ErrIDNotValid)
+		return
+	}
+	var request ChangeEmailRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(fmt.Errorf("%w: %v", errMalformedRequest, err))
+		return
+	}
+	if err := validate.Struct(request); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := h.userService.RequestEmailChange(c.Request.Context(), id, request.Email); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	writeSuccessResponse(c, http.StatusAccepted, nil)
+}
+
+// VerifyEmail godoc
+// @Summary      Подтверждение email
+// @Description  Открывается по ссылке из письма и не требует аутентификации.
+// @Description  Ссылка перестаёт действовать по истечении срока или после смены email пользователя.
+// @Tags         user
+// @Produce      json
+// @Param        token  query     string  true "Токен из письма"
+// @Success      200  {object}  Response{data=domain.User}
+// @Failure      400  {object}  Response
+// @Failure      409  {object}  Response
+// @Failure      500  {object}  Response
+// @Router       /verify-email [get]
+func (h *Handler) VerifyEmail(c *gin.Context) {
+	user, err := h.userService.VerifyEmail(c.Request.Context(), c.Query("token"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	setUserETag(c, user)
+	writeSuccessResponse(c, http.StatusOK, user)
+}
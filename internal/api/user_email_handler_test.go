@@ -0,0 +1,206 @@
+package api
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var mailedLink = regexp.MustCompile(`\S+\?token=\S+`)
+
+// mailedToken возвращает токен из ссылки в последнем письме на адрес email
+func mailedToken(t *testing.T, email string) string {
+	t.Helper()
+	// Некоторые письма отправляются в фоне
+	svc.Wait()
+	msg, ok := outbox.Last(email)
+	if !ok {
+		t.Fatalf("no email sent to %s", email)
+	}
+	link, err := url.Parse(mailedLink.FindString(msg.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return link.Query().Get("token")
+}
+
+func newEmailRouter() *gin.Engine {
+	r := newTestRouter()
+	r.POST("/user/:id/email", handler.RequestEmailChange)
+	r.GET("/verify-email", handler.VerifyEmail)
+	return r
+}
+
+func requestEmailChange(t *testing.T, r http.Handler, id uint, email string) *httptest.ResponseRecorder {
+	t.Helper()
+	body := fmt.Sprintf(`{"email": %q}`, email)
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("/user/%d/email", id), strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func verifyEmail(t *testing.T, r http.Handler, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, "/verify-email?token="+url.QueryEscape(token), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandler_EmailChange(t *testing.T) {
+	user, err := svc.CreateUser(t.Context(), "Email change", "email_old@example.com", 30, testPassword, domain.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = svc.PurgeUser(context.Background(), user.ID, 0)
+	})
+	if user.EmailVerifiedAt != nil {
+		t.Fatal("new user email is already verified")
+	}
+	r := newEmailRouter()
+
+	// Письмо отправляется при создании пользователя
+	signupToken := mailedToken(t, "email_old@example.com")
+	w := verifyEmail(t, r, signupToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("verify returned wrong status code: got %v want %v: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var verified domain.User
+	if err := decodeData(w.Body.Bytes(), &verified); err != nil {
+		t.Fatal(err)
+	}
+	if verified.EmailVerifiedAt == nil {
+		t.Error("email_verified_at is not set after verification")
+	}
+
+	w = requestEmailChange(t, r, user.ID, "email_new@example.com")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("change returned wrong status code: got %v want %v: %s", w.Code, http.StatusAccepted, w.Body.String())
+	}
+	current, err := svc.GetUserByID(t.Context(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Email != "email_old@example.com" {
+		t.Errorf("email changed before verification: %s", current.Email)
+	}
+
+	changeToken := mailedToken(t, "email_new@example.com")
+	w = verifyEmail(t, r, changeToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("verify returned wrong status code: got %v want %v: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if err := decodeData(w.Body.Bytes(), &verified); err != nil {
+		t.Fatal(err)
+	}
+	if verified.Email != "email_new@example.com" || verified.EmailVerifiedAt == nil {
+		t.Errorf("email was not changed: %+v", verified)
+	}
+
+	// После смены адреса ссылки, выданные для прежнего, не действуют
+	for _, token := range []string{signupToken, changeToken, "garbage"} {
+		w = verifyEmail(t, r, token)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("stale token: got status %v want %v: %s", w.Code, http.StatusBadRequest, w.Body.String())
+		}
+	}
+}
+
+func TestHandler_EmailChangeConflict(t *testing.T) {
+	user, err := svc.CreateUser(t.Context(), "Email conflict", "email_conflict@example.com", 30, testPassword, domain.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = svc.PurgeUser(context.Background(), user.ID, 0)
+	})
+	other, err := svc.CreateUser(t.Context(), "Email taken", "email_taken@example.com", 30, testPassword, domain.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = svc.PurgeUser(context.Background(), other.ID, 0)
+	})
+	r := newEmailRouter()
+
+	w := verifyEmail(t, r, mailedToken(t, "email_conflict@example.com"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("verify returned wrong status code: got %v want %v: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	tests := []struct {
+		email string
+		want  ErrorCode
+	}{
+		{"email_taken@example.com", CodeEmailTaken},
+		{"email_conflict@example.com", CodeEmailVerified},
+	}
+	for _, tt := range tests {
+		w := requestEmailChange(t, r, user.ID, tt.email)
+		if w.Code != http.StatusConflict {
+			t.Errorf("%s: got status %v want %v", tt.email, w.Code, http.StatusConflict)
+		}
+		var response Response
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Error == nil || response.Error.Code != tt.want {
+			t.Errorf("%s: handler returned unexpected body: %s", tt.email, w.Body.String())
+		}
+	}
+}
+
+func TestHandler_EmailChangeForbidden(t *testing.T) {
+	user, err := svc.CreateUser(t.Context(), "Email owner", "email_owner@example.com", 30, testPassword, domain.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = svc.PurgeUser(context.Background(), user.ID, 0)
+	})
+	other, err := svc.CreateUser(t.Context(), "Email other", "email_other@example.com", 30, testPassword, domain.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = svc.PurgeUser(context.Background(), other.ID, 0)
+	})
+
+	tests := []struct {
+		name      string
+		principal auth.Principal
+		wantCode  int
+	}{
+		{"api key", auth.Principal{APIKeyID: 1, Scopes: []string{auth.ScopeUsersWrite}}, http.StatusForbidden},
+		{"other member", auth.Principal{UserID: other.ID}, http.StatusForbidden},
+		{"owner", auth.Principal{UserID: user.ID}, http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		r := newTestRouter()
+		r.POST("/user/:id/email", func(c *gin.Context) {
+			setPrincipal(c, tt.principal)
+		}, handler.RequestEmailChange)
+
+		w := requestEmailChange(t, r, user.ID, "email_hijacked@example.com")
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: got status %v want %v: %s", tt.name, w.Code, tt.wantCode, w.Body.String())
+		}
+	}
+}
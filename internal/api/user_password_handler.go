@@ -0,0 +1,78 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// ForgotPassword godoc
+// @Summary      Запрос сброса пароля
+// @Description  Отправляет на email ссылку для сброса пароля. Ответ одинаков
+// @Description  независимо от того, зарегистрирован ли такой email.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request   body      ForgotPasswordRequest  true  "JSON"
+// @Success      202       {object}  Response
+// @Failure      400       {object}  Response
+// @Failure      429       {object}  Response
+// @Failure      500       {object}  Response
+// @Router       /auth/forgot-password [post]
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var request ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(fmt.Errorf("%w: %v", errMalformedRequest, err))
+		return
+	}
+	if err := validate.Struct(request); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := h.userService.ForgotPassword(c.Request.Context(), request.Email); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	writeSuccessResponse(c, http.StatusAccepted, nil)
+}
+
+// ResetPassword godoc
+// @Summary      Сброс пароля
+// @Description  Устанавливает новый пароль по токену из письма и завершает все сессии пользователя:
+// @Description  выданные ранее access- и refresh-токены перестают действовать. Токен одноразовый.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request   body      ResetPasswordRequest  true  "JSON"
+// @Success      200       {object}  Response
+// @Failure      400       {object}  Response
+// @Failure      429       {object}  Response
+// @Failure      500       {object}  Response
+// @Router       /auth/reset-password [post]
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var request ResetPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(fmt.Errorf("%w: %v", errMalformedRequest, err))
+		return
+	}
+	if err := validate.Struct(request); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := h.userService.ResetPassword(c.Request.Context(), request.Token, request.Password); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	writeSuccessResponse(c, http.StatusOK, nil)
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/domain"
+	"api_server/internal/repository"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func postAuth(t *testing.T, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := newTestRouter()
+	r.POST("/auth/forgot-password", handler.ForgotPassword)
+	r.POST("/auth/reset-password", handler.ResetPassword)
+	r.POST("/auth/refresh", authHandler.Refresh)
+
+	req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandler_ForgotPasswordUnknownEmail(t *testing.T) {
+	svc.Wait()
+	sent := len(outbox.Messages())
+	w := postAuth(t, "/auth/forgot-password", `{"email": "nobody@example.com"}`)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusAccepted)
+	}
+	svc.Wait()
+	if got := len(outbox.Messages()); got != sent {
+		t.Errorf("email sent for unknown address: %d new messages", got-sent)
+	}
+}
+
+func TestHandler_ResetPassword(t *testing.T) {
+	const email = "password_reset@example.com"
+	user, err := svc.CreateUser(t.Context(), "Password reset", email, 30, testPassword, domain.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = svc.PurgeUser(context.Background(), user.ID, 0)
+	})
+	var session auth.TokenPair
+	if err := decodeData(login(t, email, testPassword).Body.Bytes(), &session); err != nil {
+		t.Fatal(err)
+	}
+
+	w := postAuth(t, "/auth/forgot-password", fmt.Sprintf(`{"email": %q}`, email))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("forgot returned wrong status code: got %v want %v", w.Code, http.StatusAccepted)
+	}
+	token := mailedToken(t, email)
+
+	// Пароль длиннее 72 байт отклоняется, а токен остаётся действительным
+	w = postAuth(t, "/auth/reset-password", fmt.Sprintf(`{"token": %q, "password": %q}`, token, strings.Repeat("пароль", 7)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("too long password: got status %v want %v: %s", w.Code, http.StatusBadRequest, w.Body.String())
+	}
+
+	resetBody := fmt.Sprintf(`{"token": %q, "password": "new-password"}`, token)
+	w = postAuth(t, "/auth/reset-password", resetBody)
+	if w.Code != http.StatusOK {
+		t.Fatalf("reset returned wrong status code: got %v want %v: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	// Токен одноразовый
+	w = postAuth(t, "/auth/reset-password", resetBody)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("reused token: got status %v want %v", w.Code, http.StatusBadRequest)
+	}
+
+	if w := login(t, email, testPassword); w.Code != http.StatusUnauthorized {
+		t.Errorf("login with old password: got status %v want %v", w.Code, http.StatusUnauthorized)
+	}
+	if w := login(t, email, "new-password"); w.Code != http.StatusOK {
+		t.Errorf("login with new password: got status %v want %v", w.Code, http.StatusOK)
+	}
+
+	// Сессии, открытые до сброса, отозваны
+	w = postAuth(t, "/auth/refresh", fmt.Sprintf(`{"refresh_token": %q}`, session.RefreshToken))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh with revoked session: got status %v want %v", w.Code, http.StatusUnauthorized)
+	}
+	r := newTestRouter()
+	r.GET("/user/:id", Authenticate(tokens, apiKeys), handler.GetUser)
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/user/%d", user.ID), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+session.AccessToken)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("access token of revoked session: got status %v want %v", w.Code, http.StatusUnauthorized)
+	}
+
+	records, _, err := audit.GetRecords(t.Context(), repository.AuditQuery{UserID: &user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := make([]string, 0, len(records))
+	for _, record := range records {
+		actions = append(actions, record.Action)
+	}
+	for _, action := range []string{domain.AuditPasswordResetRequest, domain.AuditPasswordReset} {
+		if !slices.Contains(actions, action) {
+			t.Errorf("audit has no %s record: %v", action, actions)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+)
+
+const (
+	ScopeUsersRead   = "users:read"
+	ScopeUsersWrite  = "users:write"
+	ScopeUsersDelete = "users:delete"
+	ScopeKeysManage  = "keys:manage"
+	ScopeAuditRead   = "audit:read"
+
+	apiKeyPrefix = "ak"
+)
+
+// Scopes — все права, которые можно выдать API-ключу
+var Scopes = []string{ScopeUsersRead, ScopeUsersWrite, ScopeUsersDelete, ScopeKeysManage, ScopeAuditRead}
+
+var (
+	ErrInvalidAPIKey     = errors.New("invalid API key")
+	ErrUnknownScope      = errors.New("unknown scope")
+	ErrInsufficientScope = errors.New("insufficient scope")
+)
+
+// GenerateAPIKey создаёт ключ вида "ak_<prefix>_<secret>". Prefix хранится
+// открыто и нужен для поиска ключа, в базу попадает только хеш всего ключа.
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	prefixBytes := make([]byte, 6)
+	if _, err = rand.Read(prefixBytes); err != nil {
+		return "", "", "", err
+	}
+	secretBytes := make([]byte, 32)
+	if _, err = rand.Read(secretBytes); err != nil {
+		return "", "", "", err
+	}
+
+	prefix = hex.EncodeToString(prefixBytes)
+	key = apiKeyPrefix + "_" + prefix + "_" + base64.RawURLEncoding.EncodeToString(secretBytes)
+	return key, prefix, HashAPIKey(key), nil
+}
+
+// ParseAPIKey возвращает открытую часть ключа
+func ParseAPIKey(key string) (prefix string, err error) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", ErrInvalidAPIKey
+	}
+	return parts[1], nil
+}
+
+// HashAPIKey возвращает SHA-256 ключа. Ключ случаен и достаточно длинный,
+// поэтому медленный хеш вроде bcrypt здесь не нужен.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckAPIKey сравнивает ключ с хешем за постоянное время
+func CheckAPIKey(hash, key string) bool {
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(HashAPIKey(key))) == 1
+}
+
+// ValidateScopes проверяет, что все права известны
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return ErrUnknownScope
+		}
+	}
+	return nil
+}
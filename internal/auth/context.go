@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Principal — тот, от чьего имени выполняется запрос: пользователь,
+// вошедший по JWT, или API-ключ с набором прав
+type Principal struct {
+	UserID   uint
+	APIKeyID uint
+	// Session — поколение сессий из токена пользователя (см. Claims.Session)
+	Session uint
+	// Scopes — права API-ключа; у пользователя ограничений по правам нет
+	Scopes []string
+}
+
+// HasScope сообщает, разрешено ли действие с правом scope
+func (p Principal) HasScope(scope string) bool {
+	if p.APIKeyID == 0 {
+		return true
+	}
+	return slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// WithPrincipal возвращает контекст с аутентифицированным субъектом
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext возвращает аутентифицированного субъекта, если он есть
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// UserIDFromContext возвращает ID аутентифицированного пользователя, если запрос
+// выполняется от имени пользователя, а не API-ключа
+func UserIDFromContext(ctx context.Context) (uint, bool) {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok || p.UserID == 0 {
+		return 0, false
+	}
+	return p.UserID, true
+}
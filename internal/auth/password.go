@@ -0,0 +1,20 @@
+package auth
+
+import "golang.org/x/crypto/bcrypt"
+
+// MaxPasswordLength — наибольшая длина пароля в байтах, которую принимает bcrypt
+const MaxPasswordLength = 72
+
+// HashPassword возвращает bcrypt-хеш пароля
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword сообщает, соответствует ли пароль хешу
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
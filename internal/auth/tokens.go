@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"strconv"
+	"time"
+)
+
+const (
+	TokenTypeAccess            = "access"
+	TokenTypeRefresh           = "refresh"
+	TokenTypeEmailVerification = "email_verification"
+	TokenTypePasswordReset     = "password_reset"
+
+	DefaultAccessTTL            = 15 * time.Minute
+	DefaultRefreshTTL           = 30 * 24 * time.Hour
+	DefaultEmailVerificationTTL = 24 * time.Hour
+	DefaultPasswordResetTTL     = time.Hour
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// Claims — содержимое токена. Subject хранит ID пользователя.
+type Claims struct {
+	Type string `json:"typ"`
+	// Session — поколение сессий пользователя в access- и refresh-токенах:
+	// токены прежних поколений отозваны (см. domain.User.SessionVersion)
+	Session uint `json:"sv,omitempty"`
+	// EmailFrom и Email есть только в токенах подтверждения email: адрес пользователя
+	// на момент выдачи токена и подтверждаемый адрес
+	EmailFrom string `json:"email_from,omitempty"`
+	Email     string `json:"email,omitempty"`
+	// Password — отпечаток хеша пароля в токенах сброса пароля: после смены
+	// пароля токен перестаёт действовать
+	Password string `json:"pwd,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// TokenPair — выданные пользователю access- и refresh-токены
+type TokenPair struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	TokenType    string    `json:"token_type" example:"Bearer"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// TokenManager выпускает и проверяет токены, подписанные HMAC-SHA256
+type TokenManager struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	now        func() time.Time
+}
+
+func NewTokenManager(secret string, accessTTL, refreshTTL time.Duration) (*TokenManager, error) {
+	if secret == "" {
+		return nil, errors.New("token secret is required")
+	}
+	if accessTTL <= 0 {
+		accessTTL = DefaultAccessTTL
+	}
+	if refreshTTL <= 0 {
+		refreshTTL = DefaultRefreshTTL
+	}
+
+	return &TokenManager{
+		secret:     []byte(secret),
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+		now:        time.Now,
+	}, nil
+}
+
+// Issue выпускает пару токенов для пользователя userID в поколении сессий session
+func (m *TokenManager) Issue(userID uint, session uint) (TokenPair, error) {
+	now := m.now()
+	accessExpiresAt := now.Add(m.accessTTL)
+
+	accessClaims := newClaims(userID, TokenTypeAccess, now, accessExpiresAt)
+	accessClaims.Session = session
+	access, err := m.sign(accessClaims)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refreshClaims := newClaims(userID, TokenTypeRefresh, now, now.Add(m.refreshTTL))
+	refreshClaims.Session = session
+	refresh, err := m.sign(refreshClaims)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresAt:    accessExpiresAt,
+	}, nil
+}
+
+// IssueEmailVerification выпускает токен, подтверждающий, что пользователь userID
+// с адресом from владеет адресом email. Токен действует ttl.
+func (m *TokenManager) IssueEmailVerification(userID uint, from, email string, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		ttl = DefaultEmailVerificationTTL
+	}
+	now := m.now()
+	claims := newClaims(userID, TokenTypeEmailVerification, now, now.Add(ttl))
+	claims.EmailFrom, claims.Email = from, email
+	return m.sign(claims)
+}
+
+// IssuePasswordReset выпускает токен сброса пароля пользователя userID;
+// password — отпечаток текущего хеша пароля. Токен действует ttl.
+func (m *TokenManager) IssuePasswordReset(userID uint, password string, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		ttl = DefaultPasswordResetTTL
+	}
+	now := m.now()
+	claims := newClaims(userID, TokenTypePasswordReset, now, now.Add(ttl))
+	claims.Password = password
+	return m.sign(claims)
+}
+
+// Parse проверяет подпись, срок действия и тип токена и возвращает его содержимое
+func (m *TokenManager) Parse(token, tokenType string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return m.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithTimeFunc(m.now),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrTokenExpired
+		}
+		return nil, ErrInvalidToken
+	}
+	if claims.Type != tokenType {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// UserID возвращает ID пользователя, которому выдан токен
+func (c *Claims) UserID() (uint, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	return uint(id), nil
+}
+
+func newClaims(userID uint, tokenType string, issuedAt, expiresAt time.Time) Claims {
+	return Claims{
+		Type: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+}
+
+func (m *TokenManager) sign(claims Claims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+}
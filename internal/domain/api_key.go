@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// APIKey — долгоживущий ключ для межсервисных вызовов.
+// Секрет хранится только в виде хеша; Prefix служит для поиска ключа.
+type APIKey struct {
+	gorm.Model
+	Name       string     `json:"name"         gorm:"not null;size:255"`
+	Prefix     string     `json:"prefix"       gorm:"uniqueIndex;type:varchar(32)"`
+	SecretHash string     `json:"-"            gorm:"not null;type:varchar(64)"`
+	Scopes     string     `json:"-"            gorm:"not null;default:''"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// ScopeList возвращает права ключа списком
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
@@ -0,0 +1,34 @@
+package domain
+
+import "time"
+
+const (
+	AuditUserCreate  = "user.create"
+	AuditUserUpdate  = "user.update"
+	AuditUserDelete  = "user.delete"
+	AuditUserRestore = "user.restore"
+	AuditUserPurge   = "user.purge"
+
+	AuditPasswordResetRequest = "user.password_reset_request"
+	AuditPasswordReset        = "user.password_reset"
+)
+
+// AuditChange — значение поля до и после изменения; отсутствующее значение
+// означает, что поля не было (создание) или его больше нет (удаление)
+type AuditChange struct {
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// AuditRecord — неизменяемая запись журнала аудита об изменении пользователя.
+// Actor — кто выполнил действие: "user:<id>", "api-key:<id>" или "system".
+type AuditRecord struct {
+	ID        uint                   `json:"id"         gorm:"primarykey"`
+	CreatedAt time.Time              `json:"created_at" gorm:"index"`
+	Actor     string                 `json:"actor"      gorm:"not null;index;type:varchar(64)"`
+	Action    string                 `json:"action"     gorm:"not null;type:varchar(32)"`
+	UserID    uint                   `json:"user_id"    gorm:"not null;index"`
+	Changes   map[string]AuditChange `json:"changes"    gorm:"serializer:json"`
+	RequestID string                 `json:"request_id" gorm:"type:varchar(64)"`
+	IP        string                 `json:"ip"         gorm:"type:varchar(45)"`
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+type ExportStatus string
+
+const (
+	ExportPending   ExportStatus = "pending"
+	ExportRunning   ExportStatus = "running"
+	ExportCompleted ExportStatus = "completed"
+	ExportFailed    ExportStatus = "failed"
+)
+
+// ExportJob — фоновая выгрузка пользователей в файл. Задание видно только
+// запустившему его субъекту (Actor); готовый файл хранится до ExpiresAt.
+type ExportJob struct {
+	ID        uint         `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	Actor     string       `json:"-" gorm:"not null;type:varchar(64);index"`
+	Status    ExportStatus `json:"status" gorm:"not null;type:varchar(16);index" enums:"pending,running,completed,failed"`
+	Format    string       `json:"format" gorm:"not null;type:varchar(16)"`
+	Columns   []string     `json:"columns,omitempty" gorm:"serializer:json"`
+	// Query — фильтры и сортировка выгрузки (repository.UserQuery в JSON)
+	Query []byte `json:"-"`
+	// Total — сколько пользователей подходило под фильтры на момент запуска
+	Total     int64 `json:"total"`
+	Processed int64 `json:"processed"`
+	// Size — размер готового файла в байтах
+	Size        int64      `json:"size,omitempty"`
+	FilePath    string     `json:"-"`
+	Error       string     `json:"error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" gorm:"index"`
+}
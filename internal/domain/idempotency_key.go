@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// IdempotencyKey — сохранённый ответ на запрос с заголовком Idempotency-Key.
+// Ключ действует в пределах субъекта (Actor); StatusCode == 0 означает,
+// что первый запрос с этим ключом ещё выполняется; после LockedUntil такой
+// ключ считается брошенным и может быть занят заново.
+type IdempotencyKey struct {
+	ID          uint              `gorm:"primarykey"`
+	Actor       string            `gorm:"not null;type:varchar(64);uniqueIndex:idx_idempotency_keys_actor_key"`
+	Key         string            `gorm:"not null;type:varchar(255);uniqueIndex:idx_idempotency_keys_actor_key"`
+	RequestHash string            `gorm:"not null;type:varchar(64)"`
+	StatusCode  int               `gorm:"not null;default:0"`
+	Headers     map[string]string `gorm:"serializer:json"`
+	Body        []byte
+	CreatedAt   time.Time
+	LockedUntil time.Time
+	ExpiresAt   time.Time `gorm:"index"`
+}
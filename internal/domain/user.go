@@ -1,10 +1,34 @@
 package domain
 
-import "gorm.io/gorm"
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// Role определяет, какие действия с пользователями доступны (см. service.Policy)
+type Role string
+
+const (
+	RoleAdmin   Role = "admin"
+	RoleManager Role = "manager"
+	RoleMember  Role = "member"
+)
+
+// Roles — все допустимые роли
+var Roles = []Role{RoleAdmin, RoleManager, RoleMember}
 
 type User struct {
 	gorm.Model
 	Name  string `json:"name"  gorm:"not null;size(255)"`
 	Age   uint   `json:"age"   gorm:"not null;default:0"`
 	Email string `json:"email" gorm:"uniqueIndex;type:varchar(255)"`
+	Role  Role   `json:"role"  gorm:"not null;type:varchar(16);default:'member'"`
+	// EmailVerifiedAt — когда пользователь подтвердил, что владеет Email; nil, если не подтвердил
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// Version увеличивается при каждом изменении и служит ETag пользователя
+	Version uint `json:"version" gorm:"not null;default:1"`
+	// SessionVersion увеличивается, когда все выданные пользователю токены отзываются
+	SessionVersion uint `json:"-" gorm:"not null;default:0"`
+	// PasswordHash — bcrypt-хеш пароля, наружу не отдаётся
+	PasswordHash string `json:"-"     gorm:"type:varchar(255)"`
 }
@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// UserVersion — снимок состояния пользователя перед изменением.
+// Version снимка равна User.Version в момент снимка, поэтому номера версий
+// совпадают с ETag пользователя и могут идти с пропусками; снимки удаляются
+// вместе с пользователем только при безвозвратном удалении.
+type UserVersion struct {
+	ID        uint      `json:"-"          gorm:"primarykey"`
+	UserID    uint      `json:"user_id"    gorm:"not null;uniqueIndex:idx_user_versions_user_version"`
+	Version   uint      `json:"version"    gorm:"not null;uniqueIndex:idx_user_versions_user_version"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	Age       uint      `json:"age"`
+	Email     string    `json:"email"`
+	Role      Role      `json:"role"`
+	User      *User     `json:"-"          gorm:"constraint:OnDelete:CASCADE"`
+}
@@ -0,0 +1,196 @@
+// Package export записывает пользователей в форматах выгрузки: CSV, NDJSON и vCard.
+package export
+
+import (
+	"api_server/internal/domain"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+	FormatVCard  = "vcf"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown export format")
+	ErrUnknownColumn = errors.New("unknown export column")
+)
+
+// DefaultColumns — столбцы CSV, если они не выбраны явно
+var DefaultColumns = []string{"id", "name", "email", "age", "role", "created_at", "updated_at"}
+
+// columns — столбцы, доступные для выгрузки в CSV
+var columns = map[string]func(u *domain.User) string{
+	"id":      func(u *domain.User) string { return strconv.FormatUint(uint64(u.ID), 10) },
+	"name":    func(u *domain.User) string { return u.Name },
+	"email":   func(u *domain.User) string { return u.Email },
+	"age":     func(u *domain.User) string { return strconv.FormatUint(uint64(u.Age), 10) },
+	"role":    func(u *domain.User) string { return string(u.Role) },
+	"version": func(u *domain.User) string { return strconv.FormatUint(uint64(u.Version), 10) },
+	"created_at": func(u *domain.User) string {
+		return u.CreatedAt.UTC().Format(time.RFC3339)
+	},
+	"updated_at": func(u *domain.User) string {
+		return u.UpdatedAt.UTC().Format(time.RFC3339)
+	},
+	"deleted_at": func(u *domain.User) string {
+		if !u.DeletedAt.Valid {
+			return ""
+		}
+		return u.DeletedAt.Time.UTC().Format(time.RFC3339)
+	},
+}
+
+// Encoder записывает пользователей в формате выгрузки
+type Encoder interface {
+	Encode(users []domain.User) error
+	// Close дописывает то, что осталось в буфере; закрывать w должен вызывающий
+	Close() error
+}
+
+type format struct {
+	contentType string
+	newEncoder  func(w io.Writer, columns []string) (Encoder, error)
+}
+
+var formats = map[string]format{
+	FormatCSV:    {contentType: "text/csv; charset=utf-8", newEncoder: newCSVEncoder},
+	FormatNDJSON: {contentType: "application/x-ndjson", newEncoder: newNDJSONEncoder},
+	FormatVCard:  {contentType: "text/vcard; charset=utf-8", newEncoder: newVCardEncoder},
+}
+
+// NewEncoder возвращает Encoder формата name, пишущий в w.
+// columns учитываются только в CSV; пустой список означает DefaultColumns.
+func NewEncoder(name string, w io.Writer, columns []string) (Encoder, error) {
+	f, ok := formats[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, name)
+	}
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+	return f.newEncoder(w, columns)
+}
+
+// ContentType возвращает MIME-тип формата name
+func ContentType(name string) string {
+	return formats[name].contentType
+}
+
+// Filename возвращает имя файла выгрузки, созданной в момент t
+func Filename(name string, t time.Time) string {
+	return fmt.Sprintf("users-%s.%s", t.UTC().Format("20060102-150405"), name)
+}
+
+// ParseColumns разбирает список столбцов CSV через запятую;
+// пустая строка означает DefaultColumns
+func ParseColumns(s string) ([]string, error) {
+	if s == "" {
+		return DefaultColumns, nil
+	}
+	list := strings.Split(s, ",")
+	for i, column := range list {
+		list[i] = strings.TrimSpace(column)
+		if _, ok := columns[list[i]]; !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownColumn, list[i])
+		}
+	}
+	return list, nil
+}
+
+type csvEncoder struct {
+	w       *csv.Writer
+	columns []string
+}
+
+func newCSVEncoder(w io.Writer, columns []string) (Encoder, error) {
+	e := &csvEncoder{w: csv.NewWriter(w), columns: columns}
+	if err := e.w.Write(columns); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *csvEncoder) Encode(users []domain.User) error {
+	record := make([]string, len(e.columns))
+	for i := range users {
+		for j, column := range e.columns {
+			record[j] = escapeFormula(columns[column](&users[i]))
+		}
+		if err := e.w.Write(record); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// escapeFormula экранирует апострофом ячейку, которую табличный редактор
+// выполнил бы как формулу
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func newNDJSONEncoder(w io.Writer, _ []string) (Encoder, error) {
+	return &ndjsonEncoder{enc: json.NewEncoder(w)}, nil
+}
+
+func (e *ndjsonEncoder) Encode(users []domain.User) error {
+	for i := range users {
+		if err := e.enc.Encode(&users[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *ndjsonEncoder) Close() error { return nil }
+
+// vcardEncoder пишет пользователей визитками vCard 4.0 (RFC 6350)
+type vcardEncoder struct {
+	w io.Writer
+}
+
+func newVCardEncoder(w io.Writer, _ []string) (Encoder, error) {
+	return &vcardEncoder{w: w}, nil
+}
+
+var vcardEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`)
+
+func (e *vcardEncoder) Encode(users []domain.User) error {
+	var b strings.Builder
+	for _, u := range users {
+		name := vcardEscaper.Replace(u.Name)
+		b.WriteString("BEGIN:VCARD\r\nVERSION:4.0\r\n")
+		fmt.Fprintf(&b, "UID:urn:user:%d\r\n", u.ID)
+		fmt.Fprintf(&b, "FN:%s\r\n", name)
+		fmt.Fprintf(&b, "N:%s;;;;\r\n", name)
+		fmt.Fprintf(&b, "EMAIL:%s\r\n", vcardEscaper.Replace(u.Email))
+		fmt.Fprintf(&b, "REV:%s\r\n", u.UpdatedAt.UTC().Format("20060102T150405Z"))
+		b.WriteString("END:VCARD\r\n")
+	}
+	_, err := io.WriteString(e.w, b.String())
+	return err
+}
+
+func (e *vcardEncoder) Close() error { return nil }
@@ -0,0 +1,59 @@
+// Package logging настраивает структурированные JSON-логи. Записи, сделанные
+// с контекстом запроса (slog.InfoContext и т. п.), получают ID запроса
+// и субъекта, от имени которого он выполняется.
+package logging
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/requestctx"
+	"context"
+	"io"
+	"log/slog"
+)
+
+// New создаёт логгер, пишущий в w записи уровня level и выше
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(NewHandler(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})))
+}
+
+// NewHandler дополняет записи h сведениями о запросе из контекста
+func NewHandler(h slog.Handler) slog.Handler {
+	return contextHandler{h}
+}
+
+// ParseLevel разбирает уровень логирования: debug, info, warn или error;
+// пустая строка означает info
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	err := level.UnmarshalText([]byte(s))
+	return level, err
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if info := requestctx.From(ctx); info.ID != "" {
+		r.AddAttrs(slog.String("request_id", info.ID))
+	}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		if principal.APIKeyID != 0 {
+			r.AddAttrs(slog.Uint64("api_key_id", uint64(principal.APIKeyID)))
+		} else {
+			r.AddAttrs(slog.Uint64("user_id", uint64(principal.UserID)))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,75 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// outboxSize — сколько последних писем Outbox хранит в памяти
+const outboxSize = 1000
+
+// Outbox не отправляет письма, а складывает их в память и, если задан каталог,
+// в файлы .eml в нём — чтобы при локальной разработке письма можно было прочитать.
+// В памяти хранятся только последние outboxSize писем.
+type Outbox struct {
+	mu       sync.Mutex
+	dir      string
+	messages []Message
+	// sent — сколько писем отправлено всего; нумерует файлы
+	sent int
+}
+
+// NewOutbox создаёт ящик исходящих; пустой dir означает хранение только в памяти
+func NewOutbox(dir string) *Outbox {
+	return &Outbox{dir: dir}
+}
+
+func (o *Outbox) Send(_ context.Context, msg Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.dir != "" {
+		now := time.Now()
+		data, err := encode("outbox@localhost", msg, now)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(o.dir, 0o750); err != nil {
+			return err
+		}
+		name := fmt.Sprintf("%s-%d.eml", now.UTC().Format("20060102-150405"), o.sent+1)
+		if err := os.WriteFile(filepath.Join(o.dir, name), data, 0o640); err != nil {
+			return err
+		}
+	}
+	o.sent++
+	if len(o.messages) == outboxSize {
+		o.messages = slices.Delete(o.messages, 0, 1)
+	}
+	o.messages = append(o.messages, msg)
+	return nil
+}
+
+// Messages возвращает отправленные письма в порядке отправки
+func (o *Outbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Message(nil), o.messages...)
+}
+
+// Last возвращает последнее письмо на адрес to
+func (o *Outbox) Last(to string) (Message, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i := len(o.messages) - 1; i >= 0; i-- {
+		if o.messages[i].To == to {
+			return o.messages[i], true
+		}
+	}
+	return Message{}, false
+}
@@ -0,0 +1,101 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// DefaultSMTPTimeout — сколько по умолчанию может длиться отправка одного письма,
+// от подключения до завершения сеанса
+const DefaultSMTPTimeout = 30 * time.Second
+
+// SMTPMailer отправляет письма через SMTP-сервер. Если сервер поддерживает
+// STARTTLS, соединение шифруется. Если задан логин, используется
+// PLAIN-аутентификация (net/smtp требует для неё TLS, кроме подключения к localhost).
+type SMTPMailer struct {
+	host    string
+	addr    string
+	from    string
+	auth    smtp.Auth
+	timeout time.Duration
+}
+
+// NewSMTPMailer создаёт отправителя; timeout <= 0 означает DefaultSMTPTimeout
+func NewSMTPMailer(host, port, username, password, from string, timeout time.Duration) *SMTPMailer {
+	if timeout <= 0 {
+		timeout = DefaultSMTPTimeout
+	}
+	m := &SMTPMailer{host: host, addr: net.JoinHostPort(host, port), from: from, timeout: timeout}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+// Send отправляет письмо. Весь сеанс ограничен таймаутом отправителя
+// и сроком ctx: зависший сервер не задержит вызывающего дольше.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	data, err := encode(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer client.Close()
+	return m.send(client, msg.To, data)
+}
+
+// send повторяет smtp.SendMail на уже открытом соединении
+func (m *SMTPMailer) send(client *smtp.Client, to string, data []byte) error {
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := client.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(m.from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,125 @@
+// Package metrics собирает метрики сервиса в формате Prometheus: HTTP-запросы,
+// операции UserService, пул соединений с БД и число пользователей.
+package metrics
+
+import (
+	"api_server/internal/service"
+	"context"
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "api"
+
+// usersTotalTimeout ограничивает подсчёт пользователей при каждом опросе
+const usersTotalTimeout = 5 * time.Second
+
+// Metrics хранит метрики в собственном реестре, поэтому экземпляров
+// может быть несколько (например, в тестах)
+type Metrics struct {
+	registry   *prometheus.Registry
+	requests   *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	inFlight   prometheus.Gauge
+	operations *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route template and status.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route template and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests being served.",
+		}),
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "user_service_operations_total",
+			Help:      "UserService operations by name.",
+		}, []string{"operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "user_service_errors_total",
+			Help:      "Failed UserService operations by name and error type.",
+		}, []string{"operation", "type"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.duration,
+		m.inFlight,
+		m.operations,
+		m.errors,
+	)
+	return m
+}
+
+// Handler отдаёт метрики в текстовом формате Prometheus
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// RequestStarted и RequestFinished учитывают запрос, пока он обрабатывается;
+// route — шаблон маршрута, а не путь, чтобы число рядов метрики не росло с ID
+func (m *Metrics) RequestStarted() {
+	m.inFlight.Inc()
+}
+
+func (m *Metrics) RequestFinished(method, route string, status int, latency time.Duration) {
+	m.inFlight.Dec()
+	labels := prometheus.Labels{"method": method, "route": route, "status": strconv.Itoa(status)}
+	m.requests.With(labels).Inc()
+	m.duration.With(labels).Observe(latency.Seconds())
+}
+
+// Observe учитывает операцию UserService (см. service.Observer)
+func (m *Metrics) Observe(operation string, err error) {
+	m.operations.WithLabelValues(operation).Inc()
+	if err != nil {
+		m.errors.WithLabelValues(operation, service.ErrorType(err)).Inc()
+	}
+}
+
+// RegisterDB добавляет статистику пула соединений db (sql.DB.Stats) с меткой db_name
+func (m *Metrics) RegisterDB(name string, db *sql.DB) error {
+	return m.registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterUsersTotal добавляет число пользователей, которое count считает при каждом опросе
+func (m *Metrics) RegisterUsersTotal(count func(ctx context.Context) (int64, error)) error {
+	return m.registry.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "users_total",
+		Help:      "Users that are not deleted.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), usersTotalTimeout)
+		defer cancel()
+
+		total, err := count(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "count users for metrics", "error", err)
+			return math.NaN()
+		}
+		return float64(total)
+	}))
+}
@@ -0,0 +1,32 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStore_Cleanup(t *testing.T) {
+	now := time.Now()
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	limit := Limit{Requests: 2, Period: time.Minute}
+
+	for _, key := range []string{"idle", "busy"} {
+		if _, err := s.Take(t.Context(), key, limit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Через 30 секунд корзина idle наполнилась, а busy снова расходуется
+	now = now.Add(30 * time.Second)
+	if _, err := s.Take(t.Context(), "busy", limit); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Cleanup()
+	if _, ok := s.buckets["idle"]; ok {
+		t.Error("idle bucket is not removed")
+	}
+	if _, ok := s.buckets["busy"]; !ok {
+		t.Error("busy bucket is removed")
+	}
+}
@@ -0,0 +1,69 @@
+// Package ratelimit ограничивает частоту запросов алгоритмом token bucket.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidLimit = errors.New("invalid rate limit")
+
+// Limit — не более Requests запросов за Period. Запас не копится сверх
+// Requests: после простоя можно сразу выполнить не больше Requests запросов.
+// Нулевой Limit означает отсутствие ограничения.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit разбирает ограничение в виде "<запросов>/<период>", например "100/1m";
+// "off" и пустая строка означают отсутствие ограничения
+func ParseLimit(s string) (Limit, error) {
+	if s == "" || s == "off" {
+		return Limit{}, nil
+	}
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, s)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, s)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, s)
+	}
+	return Limit{Requests: n, Period: d}, nil
+}
+
+func (l Limit) IsZero() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+// rate — сколько запросов восстанавливается за секунду
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Result — решение по запросу и состояние корзины после него
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset — через сколько корзина наполнится полностью
+	Reset time.Duration
+	// RetryAfter — через сколько можно повторить отклонённый запрос
+	RetryAfter time.Duration
+}
+
+// Store хранит корзины по ключам. Реализация для нескольких экземпляров
+// сервиса (например, Redis) должна списывать запрос атомарно.
+type Store interface {
+	// Take списывает один запрос из корзины key с ограничением limit
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Limit
+		wantErr bool
+	}{
+		{"100/1m", Limit{Requests: 100, Period: time.Minute}, false},
+		{"", Limit{}, false},
+		{"off", Limit{}, false},
+		{"0/1m", Limit{}, true},
+		{"10", Limit{}, true},
+		{"10/-1s", Limit{}, true},
+		{"ten/1m", Limit{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.s)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidLimit) {
+				t.Errorf("ParseLimit(%q) error = %v, want %v", tt.s, err, ErrInvalidLimit)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, %v, want %+v", tt.s, got, err, tt.want)
+		}
+	}
+}
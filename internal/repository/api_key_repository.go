@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"api_server/internal/domain"
+	"context"
+	"time"
+)
+
+type APIKeyRepositoryInterface interface {
+	GetAll(ctx context.Context) ([]domain.APIKey, error)
+	GetByID(ctx context.Context, id uint) (*domain.APIKey, error)
+	GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error)
+	Create(ctx context.Context, key *domain.APIKey) error
+	Revoke(ctx context.Context, id uint, at time.Time) error
+	TouchLastUsed(ctx context.Context, id uint, at time.Time) error
+}
@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"api_server/internal/domain"
+	"context"
+	"time"
+)
+
+// AuditQuery — фильтры и страница журнала аудита
+type AuditQuery struct {
+	UserID *uint
+	Actor  string
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+	Offset int
+}
+
+// AuditRepositoryInterface не позволяет изменять и удалять записи:
+// журнал аудита только дополняется
+type AuditRepositoryInterface interface {
+	Create(ctx context.Context, record *domain.AuditRecord) error
+	GetAll(ctx context.Context, query AuditQuery) ([]domain.AuditRecord, int64, error)
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 	"strings"
 )
 
@@ -15,6 +16,8 @@ type Database struct {
 	DBName   string
 	SSLMode  string
 	DSN      string
+	// Logger — логгер запросов gorm; nil означает логгер gorm по умолчанию
+	Logger logger.Interface
 }
 
 func NewDB(host, port, user, password, DBName, SSLMode string) *Database {
@@ -58,7 +61,7 @@ func (db *Database) ValidateConfig() error {
 
 func (db *Database) Connect() (*gorm.DB, error) {
 	dsn := db.BuildDsn()
-	gormDb, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	gormDb, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true, Logger: db.Logger})
 	if err != nil {
 		return nil, err
 	}
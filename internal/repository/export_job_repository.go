@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"api_server/internal/domain"
+	"context"
+	"time"
+)
+
+type ExportJobRepositoryInterface interface {
+	Create(ctx context.Context, job *domain.ExportJob) error
+	Get(ctx context.Context, id uint) (*domain.ExportJob, error)
+	// ClaimPending переводит самое старое ожидающее задание в ExportRunning и возвращает его;
+	// если ожидающих заданий нет, возвращается service.ErrExportNotFound
+	ClaimPending(ctx context.Context) (*domain.ExportJob, error)
+	// Update сохраняет состояние и прогресс задания
+	Update(ctx context.Context, job *domain.ExportJob) error
+	// RequeueRunning возвращает выполнявшиеся задания в очередь
+	RequeueRunning(ctx context.Context) (int64, error)
+	// DeleteExpired удаляет задания, срок хранения файлов которых истёк раньше now,
+	// и возвращает их
+	DeleteExpired(ctx context.Context, now time.Time) ([]domain.ExportJob, error)
+}
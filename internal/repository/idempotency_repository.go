@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"api_server/internal/domain"
+	"context"
+	"time"
+)
+
+type IdempotencyRepositoryInterface interface {
+	Get(ctx context.Context, actor, key string) (*domain.IdempotencyKey, error)
+	// Create сохраняет ключ; если такой ключ уже есть, возвращает service.ErrIdempotencyKeyInProgress
+	Create(ctx context.Context, record *domain.IdempotencyKey) error
+	Complete(ctx context.Context, record *domain.IdempotencyKey) error
+	Delete(ctx context.Context, id uint) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
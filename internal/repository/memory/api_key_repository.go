@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"api_server/internal/domain"
+	"api_server/internal/service"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+func (r *APIKeyRepository) GetAll(ctx context.Context) ([]domain.APIKey, error) {
+	return gorm.G[domain.APIKey](conn(ctx, r.db)).Order("id").Find(ctx)
+}
+
+func (r *APIKeyRepository) GetByID(ctx context.Context, id uint) (*domain.APIKey, error) {
+	key, err := gorm.G[domain.APIKey](conn(ctx, r.db)).Where("id = ?", id).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, service.ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *APIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	key, err := gorm.G[domain.APIKey](conn(ctx, r.db)).Where("prefix = ?", prefix).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, service.ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *APIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	return gorm.G[domain.APIKey](conn(ctx, r.db)).Create(ctx, key)
+}
+
+func (r *APIKeyRepository) Revoke(ctx context.Context, id uint, at time.Time) error {
+	rows, err := gorm.G[domain.APIKey](conn(ctx, r.db)).Where("id = ? AND revoked_at IS NULL", id).Update(ctx, "RevokedAt", at)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		if _, err := r.GetByID(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) TouchLastUsed(ctx context.Context, id uint, at time.Time) error {
+	_, err := gorm.G[domain.APIKey](conn(ctx, r.db)).Where("id = ?", id).Update(ctx, "LastUsedAt", at)
+	return err
+}
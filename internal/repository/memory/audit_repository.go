@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"api_server/internal/domain"
+	"api_server/internal/repository"
+	"context"
+	"gorm.io/gorm"
+)
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) Create(ctx context.Context, record *domain.AuditRecord) error {
+	return gorm.G[domain.AuditRecord](conn(ctx, r.db)).Create(ctx, record)
+}
+
+// GetAll возвращает записи от новых к старым и общее количество подходящих записей
+func (r *AuditRepository) GetAll(ctx context.Context, query repository.AuditQuery) ([]domain.AuditRecord, int64, error) {
+	q := gorm.G[domain.AuditRecord](conn(ctx, r.db)).Scopes(filterAudit(query))
+
+	total, err := q.Count(ctx, "id")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	q = q.Order("created_at DESC, id DESC")
+	if query.Limit > 0 {
+		q = q.Limit(query.Limit)
+	}
+	if query.Offset > 0 {
+		q = q.Offset(query.Offset)
+	}
+
+	records, err := q.Find(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return records, total, nil
+}
+
+func filterAudit(query repository.AuditQuery) func(*gorm.Statement) {
+	return func(stmt *gorm.Statement) {
+		if query.UserID != nil {
+			stmt.Where("user_id = ?", *query.UserID)
+		}
+		if query.Actor != "" {
+			stmt.Where("actor = ?", query.Actor)
+		}
+		if query.From != nil {
+			stmt.Where("created_at >= ?", *query.From)
+		}
+		if query.To != nil {
+			stmt.Where("created_at <= ?", *query.To)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package memory
+
+import (
+	"api_server/internal/domain"
+	"api_server/internal/repository"
+	"gorm.io/gorm"
+)
+
+// Open подключается к базе данных по конфигурации и применяет миграции
+func Open(config *repository.Database) (*gorm.DB, error) {
+	if err := config.ValidateConfig(); err != nil {
+		return nil, err
+	}
+
+	db, err := config.Connect()
+	if err != nil {
+		return nil, err
+	}
+	if err := Migrate(db); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Migrate создаёт и обновляет таблицы всех моделей
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&domain.User{},
+		&domain.UserVersion{},
+		&domain.APIKey{},
+		&domain.AuditRecord{},
+		&domain.IdempotencyKey{},
+		&domain.ExportJob{},
+	)
+}
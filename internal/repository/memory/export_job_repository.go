@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"api_server/internal/domain"
+	"api_server/internal/service"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+type ExportJobRepository struct {
+	db *gorm.DB
+}
+
+func NewExportJobRepository(db *gorm.DB) *ExportJobRepository {
+	return &ExportJobRepository{db: db}
+}
+
+func (r *ExportJobRepository) Create(ctx context.Context, job *domain.ExportJob) error {
+	return gorm.G[domain.ExportJob](conn(ctx, r.db)).Create(ctx, job)
+}
+
+func (r *ExportJobRepository) Get(ctx context.Context, id uint) (*domain.ExportJob, error) {
+	job, err := gorm.G[domain.ExportJob](conn(ctx, r.db)).Where("id = ?", id).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, service.ErrExportNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *ExportJobRepository) ClaimPending(ctx context.Context) (*domain.ExportJob, error) {
+	for {
+		job, err := gorm.G[domain.ExportJob](conn(ctx, r.db)).Where("status = ?", domain.ExportPending).Order("id").First(ctx)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, service.ErrExportNotFound
+			}
+			return nil, err
+		}
+		// Задание могли забрать между выборкой и обновлением — тогда берём следующее
+		rows, err := gorm.G[domain.ExportJob](conn(ctx, r.db)).
+			Where("id = ? AND status = ?", job.ID, domain.ExportPending).
+			Update(ctx, "status", domain.ExportRunning)
+		if err != nil {
+			return nil, err
+		}
+		if rows == 1 {
+			job.Status = domain.ExportRunning
+			return &job, nil
+		}
+	}
+}
+
+func (r *ExportJobRepository) Update(ctx context.Context, job *domain.ExportJob) error {
+	_, err := gorm.G[domain.ExportJob](conn(ctx, r.db)).
+		Where("id = ?", job.ID).
+		Select("Status", "Total", "Processed", "Size", "FilePath", "Error", "CompletedAt", "ExpiresAt").
+		Updates(ctx, *job)
+	return err
+}
+
+func (r *ExportJobRepository) RequeueRunning(ctx context.Context) (int64, error) {
+	rows, err := gorm.G[domain.ExportJob](conn(ctx, r.db)).
+		Where("status = ?", domain.ExportRunning).
+		Select("Status", "Processed").
+		Updates(ctx, domain.ExportJob{Status: domain.ExportPending})
+	return int64(rows), err
+}
+
+func (r *ExportJobRepository) DeleteExpired(ctx context.Context, now time.Time) ([]domain.ExportJob, error) {
+	var jobs []domain.ExportJob
+	err := conn(ctx, r.db).WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		jobs, err = gorm.G[domain.ExportJob](tx).Where("expires_at < ?", now).Find(ctx)
+		if err != nil || len(jobs) == 0 {
+			return err
+		}
+		ids := make([]uint, len(jobs))
+		for i, job := range jobs {
+			ids[i] = job.ID
+		}
+		_, err = gorm.G[domain.ExportJob](tx).Where("id IN ?", ids).Delete(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
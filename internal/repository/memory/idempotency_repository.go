@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"api_server/internal/domain"
+	"api_server/internal/service"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+func (r *IdempotencyRepository) Get(ctx context.Context, actor, key string) (*domain.IdempotencyKey, error) {
+	record, err := gorm.G[domain.IdempotencyKey](conn(ctx, r.db)).Where("actor = ? AND key = ?", actor, key).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, service.ErrIdempotencyKeyNotFound
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r *IdempotencyRepository) Create(ctx context.Context, record *domain.IdempotencyKey) error {
+	err := gorm.G[domain.IdempotencyKey](conn(ctx, r.db)).Create(ctx, record)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return service.ErrIdempotencyKeyInProgress
+	}
+	return err
+}
+
+func (r *IdempotencyRepository) Complete(ctx context.Context, record *domain.IdempotencyKey) error {
+	_, err := gorm.G[domain.IdempotencyKey](conn(ctx, r.db)).
+		Where("id = ?", record.ID).
+		Select("StatusCode", "Headers", "Body").
+		Updates(ctx, *record)
+	return err
+}
+
+func (r *IdempotencyRepository) Delete(ctx context.Context, id uint) error {
+	_, err := gorm.G[domain.IdempotencyKey](conn(ctx, r.db)).Where("id = ?", id).Delete(ctx)
+	return err
+}
+
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	rows, err := gorm.G[domain.IdempotencyKey](conn(ctx, r.db)).Where("expires_at < ?", now).Delete(ctx)
+	return int64(rows), err
+}
@@ -1,19 +1,19 @@
 package memory
 
 import (
-	"api_server/internal/domain"
-	"context"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"log"
+	"sync"
 	"time"
 )
 
-func NewMockMemoryUserRepository() *UserRepository {
+// mockDB — общая ин‑мемори база для всех мок‑репозиториев
+var mockDB = sync.OnceValue(func() *gorm.DB {
 	// Один общий ин‑мемори инстанс для всех соединений
 	dsn := "file::memory:?cache=shared"
 
-	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		log.Fatalf("open db: %v", err)
 	}
@@ -28,15 +28,40 @@ func NewMockMemoryUserRepository() *UserRepository {
 	sqlDB.SetConnMaxLifetime(5 * time.Minute)
 
 	// Миграция схемы
-	err = db.AutoMigrate(&domain.User{})
+	err = Migrate(db)
 	if err != nil {
 		log.Fatalf("migrate: %v", err)
 	}
 
-	return &UserRepository{
-		db:  db,
-		ctx: context.Background(),
-	}
+	return db
+})
+
+func NewMockMemoryUserRepository() *UserRepository {
+	return NewUserRepository(mockDB())
+}
+
+func NewMockMemoryUserHistoryRepository() *UserHistoryRepository {
+	return NewUserHistoryRepository(mockDB())
+}
+
+func NewMockMemoryAPIKeyRepository() *APIKeyRepository {
+	return NewAPIKeyRepository(mockDB())
+}
+
+func NewMockMemoryIdempotencyRepository() *IdempotencyRepository {
+	return NewIdempotencyRepository(mockDB())
+}
+
+func NewMockMemoryExportJobRepository() *ExportJobRepository {
+	return NewExportJobRepository(mockDB())
+}
+
+func NewMockMemoryTransactor() *Transactor {
+	return NewTransactor(mockDB())
+}
+
+func NewMockMemoryAuditRepository() *AuditRepository {
+	return NewAuditRepository(mockDB())
 }
 
 //func (r *MockUserRepository) GetAll() ([]domain.User, error) {
@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"api_server/internal/domain"
+	"api_server/internal/service"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+)
+
+type UserHistoryRepository struct {
+	db *gorm.DB
+}
+
+func NewUserHistoryRepository(db *gorm.DB) *UserHistoryRepository {
+	return &UserHistoryRepository{db: db}
+}
+
+func (r *UserHistoryRepository) Create(ctx context.Context, user *domain.User) (*domain.UserVersion, error) {
+	version := &domain.UserVersion{
+		UserID:  user.ID,
+		Version: user.Version,
+		Name:    user.Name,
+		Age:     user.Age,
+		Email:   user.Email,
+		Role:    user.Role,
+	}
+	if err := gorm.G[domain.UserVersion](conn(ctx, r.db)).Create(ctx, version); err != nil {
+		return nil, err
+	}
+
+	return version, nil
+}
+
+func (r *UserHistoryRepository) GetAll(ctx context.Context, userID uint) ([]domain.UserVersion, error) {
+	return gorm.G[domain.UserVersion](conn(ctx, r.db)).Where("user_id = ?", userID).Order("version DESC").Find(ctx)
+}
+
+func (r *UserHistoryRepository) Get(ctx context.Context, userID, version uint) (*domain.UserVersion, error) {
+	v, err := gorm.G[domain.UserVersion](conn(ctx, r.db)).Where("user_id = ? AND version = ?", userID, version).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, service.ErrVersionNotFound
+		}
+		return nil, err
+	}
+	return &v, nil
+}
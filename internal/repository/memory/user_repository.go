@@ -7,45 +7,155 @@ import (
 	"context"
 	"errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"slices"
+	"strings"
+	"time"
 )
 
 type UserRepository struct {
-	db    *gorm.DB
-	ctx   context.Context
-	users []domain.User
+	db *gorm.DB
 }
 
-func NewUserRepository(config *repository.Database) (*UserRepository, error) {
-	err := config.ValidateConfig()
-	if err == nil {
-		db, err := config.Connect()
-		if err != nil {
-			panic("failed to connect database")
-		}
+func NewUserRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+func (r *UserRepository) GetAll(ctx context.Context, query repository.UserQuery) ([]domain.User, int64, error) {
+	q := gorm.G[domain.User](conn(ctx, r.db)).Scopes(filterUsers(query.Filter))
+
+	total, err := q.Count(ctx, "id")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, field := range query.Sort {
+		q = q.Order(clause.OrderByColumn{Column: clause.Column{Name: field.Column}, Desc: field.Desc})
+	}
+	// Добавляем id последним ключом, чтобы порядок страниц был детерминированным
+	q = q.Order("id")
+
+	if query.Limit > 0 {
+		q = q.Limit(query.Limit)
+	}
+	if query.Offset > 0 {
+		q = q.Offset(query.Offset)
+	}
 
-		err = db.AutoMigrate(&domain.User{})
+	users, err := q.Find(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// GetAllByCursor выбирает страницу пользователей по ключу (keyset) вместо OFFSET:
+// записи отбираются сравнением с граничной парой (значение сортировки, id),
+// поэтому стоимость запроса не растёт с номером страницы. Учитывается только
+// первое поле сортировки из query.Sort; при cursor == nil возвращается первая страница.
+// Второй результат сообщает, есть ли ещё записи в направлении выборки.
+func (r *UserRepository) GetAllByCursor(ctx context.Context, query repository.UserQuery, cursor *repository.Cursor) ([]domain.User, bool, error) {
+	sort := repository.SortField{Column: "id"}
+	if len(query.Sort) > 0 {
+		sort = query.Sort[0]
+	}
+	backward := false
+	if cursor != nil {
+		sort = cursor.Sort
+		backward = cursor.Backward
+	}
+
+	// При выборке назад идём в обратном порядке, а затем разворачиваем результат
+	desc := sort.Desc != backward
+	op := ">"
+	if desc {
+		op = "<"
+	}
+
+	q := gorm.G[domain.User](conn(ctx, r.db)).Scopes(filterUsers(query.Filter))
+	if cursor != nil {
+		value, err := cursor.Arg()
 		if err != nil {
-			panic(err)
+			return nil, false, err
 		}
+		if sort.Column == "id" {
+			q = q.Where("id "+op+" ?", cursor.ID)
+		} else {
+			q = q.Where(
+				"(("+sort.Column+" "+op+" ?) OR ("+sort.Column+" = ? AND id "+op+" ?))",
+				value, value, cursor.ID,
+			)
+		}
+	}
+	if sort.Column != "id" {
+		q = q.Order(clause.OrderByColumn{Column: clause.Column{Name: sort.Column}, Desc: desc})
+	}
+	q = q.Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}, Desc: desc})
 
-		return &UserRepository{
-			db:  db,
-			ctx: context.Background(),
-		}, nil
+	limit := query.Limit
+	if limit > 0 {
+		// Запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+		q = q.Limit(limit + 1)
 	}
-	return nil, err
-}
-func (r *UserRepository) GetAll() ([]domain.User, error) {
-	users, err := gorm.G[domain.User](r.db).Find(r.ctx)
+
+	users, err := q.Find(ctx)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+
+	hasMore := limit > 0 && len(users) > limit
+	if hasMore {
+		users = users[:limit]
+	}
+	if backward {
+		slices.Reverse(users)
 	}
 
-	return users, nil
+	return users, hasMore, nil
 }
 
-func (r *UserRepository) GetByID(id uint) (*domain.User, error) {
-	user, err := gorm.G[domain.User](r.db).Where("id = ?", id).First(r.ctx)
+func filterUsers(f repository.UserFilter) func(*gorm.Statement) {
+	return func(stmt *gorm.Statement) {
+		switch f.Deleted {
+		case repository.IncludeDeleted:
+			unscoped(stmt)
+		case repository.OnlyDeleted:
+			unscoped(stmt)
+			stmt.Where("deleted_at IS NOT NULL")
+		}
+		if f.Name != "" {
+			stmt.Where("LOWER(name) LIKE ? ESCAPE '\\'", "%"+escapeLike(strings.ToLower(f.Name))+"%")
+		}
+		if f.Email != "" {
+			stmt.Where("email = ?", f.Email)
+		}
+		if f.MinAge != nil {
+			stmt.Where("age >= ?", *f.MinAge)
+		}
+		if f.MaxAge != nil {
+			stmt.Where("age <= ?", *f.MaxAge)
+		}
+		if f.CreatedFrom != nil {
+			stmt.Where("created_at >= ?", *f.CreatedFrom)
+		}
+		if f.CreatedTo != nil {
+			stmt.Where("created_at <= ?", *f.CreatedTo)
+		}
+	}
+}
+
+// unscoped снимает условие мягкого удаления: запрос видит и удалённые записи
+func unscoped(stmt *gorm.Statement) {
+	stmt.Unscoped = true
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(s)
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id uint) (*domain.User, error) {
+	user, err := gorm.G[domain.User](conn(ctx, r.db)).Where("id = ?", id).First(ctx)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, service.ErrNotFound
@@ -55,8 +165,8 @@ func (r *UserRepository) GetByID(id uint) (*domain.User, error) {
 	return &user, nil
 }
 
-func (r *UserRepository) GetByName(name string) (*domain.User, error) {
-	user, err := gorm.G[domain.User](r.db).Where("name = ?", name).First(r.ctx)
+func (r *UserRepository) GetByName(ctx context.Context, name string) (*domain.User, error) {
+	user, err := gorm.G[domain.User](conn(ctx, r.db)).Where("name = ?", name).First(ctx)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, service.ErrNotFound
@@ -66,51 +176,237 @@ func (r *UserRepository) GetByName(name string) (*domain.User, error) {
 	return &user, nil
 }
 
-func (r *UserRepository) Create(name, email string, age uint) (*domain.User, error) {
-	err := gorm.G[domain.User](r.db).Create(r.ctx, &domain.User{
-		Name:  name,
-		Age:   age,
-		Email: email,
-	})
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	user, err := gorm.G[domain.User](conn(ctx, r.db)).Where("email = ?", email).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, service.ErrNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
 
+func (r *UserRepository) Create(ctx context.Context, name, email string, age uint, passwordHash string, role domain.Role) (*domain.User, error) {
+	user := &domain.User{
+		Name:         name,
+		Age:          age,
+		Email:        email,
+		Role:         role,
+		Version:      1,
+		PasswordHash: passwordHash,
+	}
+	err := gorm.G[domain.User](conn(ctx, r.db)).Create(ctx, user)
 	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, service.ErrEmailTaken
+		}
 		return nil, err
 	}
 
-	users, err := r.GetAll()
+	return user, nil
+}
+
+func (r *UserRepository) CreateMany(ctx context.Context, users []*domain.User) ([]bool, error) {
+	created := make([]bool, len(users))
+	for i, user := range users {
+		user.Version = 1
+		result := conn(ctx, r.db).WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(user)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		created[i] = result.RowsAffected > 0
+	}
+	return created, nil
+}
+
+// existingEmailsBatch ограничивает число параметров в одном запросе
+const existingEmailsBatch = 500
+
+func (r *UserRepository) ExistingEmails(ctx context.Context, emails []string) ([]string, error) {
+	var existing []string
+	for batch := range slices.Chunk(emails, existingEmailsBatch) {
+		found, err := gorm.G[domain.User](conn(ctx, r.db)).
+			Scopes(unscoped).
+			Where("email IN ?", batch).
+			Select("email").
+			Find(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range found {
+			existing = append(existing, user.Email)
+		}
+	}
+	return existing, nil
+}
+
+func (r *UserRepository) Update(ctx context.Context, id uint, name string, age uint, version uint) (*domain.User, error) {
+	rows, err := gorm.G[domain.User](conn(ctx, r.db)).
+		Where("id = ? AND version = ?", id, version).
+		Select("Name", "Age", "Version").
+		Updates(ctx, domain.User{Name: name, Age: age, Version: version + 1})
 	if err != nil {
 		return nil, err
 	}
+	if rows == 0 {
+		return nil, r.notUpdated(ctx, id, false)
+	}
 
-	user, err := r.GetByID(uint(len(users)))
+	return r.GetByID(ctx, id)
+}
 
-	return user, nil
+func (r *UserRepository) SetRole(ctx context.Context, id uint, role domain.Role, version uint) (*domain.User, error) {
+	rows, err := gorm.G[domain.User](conn(ctx, r.db)).
+		Where("id = ? AND version = ?", id, version).
+		Select("Role", "Version").
+		Updates(ctx, domain.User{Role: role, Version: version + 1})
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, r.notUpdated(ctx, id, false)
+	}
+
+	return r.GetByID(ctx, id)
 }
 
-func (r *UserRepository) Update(id uint, name string, age uint) (*domain.User, error) {
-	_, err := gorm.G[domain.User](r.db).Where("id = ?", id).Update(r.ctx, "Name", name)
+func (r *UserRepository) SetPassword(ctx context.Context, id uint, oldHash, newHash string, session uint) (*domain.User, error) {
+	rows, err := gorm.G[domain.User](conn(ctx, r.db)).
+		Where("id = ? AND password_hash = ? AND session_version = ?", id, oldHash, session).
+		Select("PasswordHash", "SessionVersion").
+		Updates(ctx, domain.User{PasswordHash: newHash, SessionVersion: session + 1})
 	if err != nil {
 		return nil, err
 	}
+	if rows == 0 {
+		return nil, r.notUpdated(ctx, id, false)
+	}
+
+	return r.GetByID(ctx, id)
+}
 
-	_, err = gorm.G[domain.User](r.db).Where("id = ?", id).Update(r.ctx, "Age", age)
+func (r *UserRepository) SetEmail(ctx context.Context, id uint, email string, verifiedAt time.Time, version uint) (*domain.User, error) {
+	rows, err := gorm.G[domain.User](conn(ctx, r.db)).
+		Where("id = ? AND version = ?", id, version).
+		Select("Email", "EmailVerifiedAt", "Version").
+		Updates(ctx, domain.User{Email: email, EmailVerifiedAt: &verifiedAt, Version: version + 1})
 	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, service.ErrEmailTaken
+		}
 		return nil, err
 	}
+	if rows == 0 {
+		return nil, r.notUpdated(ctx, id, false)
+	}
+
+	return r.GetByID(ctx, id)
+}
+
+func (r *UserRepository) Delete(ctx context.Context, id uint, version uint) error {
+	rows, err := gorm.G[domain.User](conn(ctx, r.db)).Scopes(withVersion(id, version)).
+		Set(softDelete(time.Now())...).
+		Update(ctx)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return r.notUpdated(ctx, id, false)
+	}
+
+	return nil
+}
+
+// softDelete помечает пользователя удалённым в deletedAt (nil — восстановленным).
+// Версия и UpdatedAt меняются, как при любом изменении, чтобы ETag и
+// Last-Modified, выданные до удаления или восстановления, устарели.
+func softDelete(deletedAt any) []clause.Assigner {
+	return []clause.Assigner{
+		clause.Assignment{Column: clause.Column{Name: "deleted_at"}, Value: deletedAt},
+		clause.Assignment{Column: clause.Column{Name: "updated_at"}, Value: time.Now()},
+		clause.Assignment{Column: clause.Column{Name: "version"}, Value: gorm.Expr("version + 1")},
+	}
+}
+
+// withVersion отбирает пользователя id, если его версия равна version;
+// при version == 0 версия не проверяется
+func withVersion(id, version uint) func(*gorm.Statement) {
+	return func(stmt *gorm.Statement) {
+		stmt.Where("id = ?", id)
+		if version != 0 {
+			stmt.Where("version = ?", version)
+		}
+	}
+}
+
+// notUpdated объясняет, почему условное изменение не затронуло ни одной строки:
+// пользователя нет или его версия уже другая
+func (r *UserRepository) notUpdated(ctx context.Context, id uint, withDeleted bool) error {
+	q := gorm.G[domain.User](conn(ctx, r.db)).Where("id = ?", id)
+	if withDeleted {
+		q = q.Scopes(unscoped)
+	}
+	count, err := q.Count(ctx, "id")
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return service.ErrNotFound
+	}
+	return service.ErrVersionMismatch
+}
 
-	user, err := r.GetByID(id)
+func (r *UserRepository) Restore(ctx context.Context, id uint) (*domain.User, error) {
+	rows, err := gorm.G[domain.User](conn(ctx, r.db)).Scopes(unscoped).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Set(softDelete(nil)...).
+		Update(ctx)
 	if err != nil {
 		return nil, err
 	}
+	if rows == 0 {
+		// Пользователь либо не существует, либо не удалён
+		if _, err := r.GetByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, service.ErrNotDeleted
+	}
 
-	return user, nil
+	return r.GetByID(ctx, id)
 }
 
-func (r *UserRepository) Delete(id uint) error {
-	_, err := gorm.G[domain.User](r.db).Where("id = ?", id).Delete(r.ctx)
+func (r *UserRepository) Purge(ctx context.Context, id uint, version uint) error {
+	rows, err := gorm.G[domain.User](conn(ctx, r.db)).Scopes(unscoped, withVersion(id, version)).Delete(ctx)
 	if err != nil {
 		return err
 	}
+	if rows == 0 {
+		return r.notUpdated(ctx, id, true)
+	}
 
 	return nil
 }
+
+func (r *UserRepository) PurgeDeletedBefore(ctx context.Context, before time.Time) ([]uint, error) {
+	var ids []uint
+	err := conn(ctx, r.db).WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		expired, err := gorm.G[domain.User](tx).Scopes(unscoped).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+			Select("id").
+			Find(ctx)
+		if err != nil || len(expired) == 0 {
+			return err
+		}
+		for _, u := range expired {
+			ids = append(ids, u.ID)
+		}
+		_, err = gorm.G[domain.User](tx).Scopes(unscoped).Where("id IN ?", ids).Delete(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
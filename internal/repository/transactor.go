@@ -0,0 +1,12 @@
+package repository
+
+import "context"
+
+// Transactor выполняет fn в одной транзакции БД: репозитории, вызванные
+// с контекстом fn, работают внутри неё. Ошибка fn откатывает транзакцию.
+type Transactor interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+	// AfterCommit вызывает fn после фиксации транзакции из ctx или сразу,
+	// если транзакции нет; при откате fn не вызывается
+	AfterCommit(ctx context.Context, fn func())
+}
@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"api_server/internal/domain"
+	"context"
+)
+
+type UserHistoryRepositoryInterface interface {
+	// Create сохраняет снимок пользователя под его версией user.Version
+	Create(ctx context.Context, user *domain.User) (*domain.UserVersion, error)
+	// GetAll возвращает версии пользователя от новых к старым
+	GetAll(ctx context.Context, userID uint) ([]domain.UserVersion, error)
+	Get(ctx context.Context, userID, version uint) (*domain.UserVersion, error)
+}
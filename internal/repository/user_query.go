@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"api_server/internal/domain"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UserSortColumns — поля, по которым разрешена сортировка списка пользователей,
+// и соответствующие им колонки таблицы.
+var UserSortColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"email":      "email",
+	"age":        "age",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+var ErrInvalidSort = errors.New("invalid sort field")
+
+// DeletedFilter определяет, попадают ли в выборку удалённые (soft delete) пользователи
+type DeletedFilter int
+
+const (
+	// ExcludeDeleted — только действующие пользователи
+	ExcludeDeleted DeletedFilter = iota
+	// IncludeDeleted — действующие и удалённые пользователи
+	IncludeDeleted
+	// OnlyDeleted — только удалённые пользователи
+	OnlyDeleted
+)
+
+type UserFilter struct {
+	Deleted     DeletedFilter
+	Name        string
+	Email       string
+	MinAge      *uint
+	MaxAge      *uint
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+}
+
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+type UserQuery struct {
+	Filter UserFilter
+	Sort   []SortField
+	Limit  int
+	Offset int
+}
+
+// ParseUserSort разбирает строку вида "-created_at,name": минус перед полем
+// означает сортировку по убыванию. Допускаются только поля из UserSortColumns.
+func ParseUserSort(s string) ([]SortField, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var fields []SortField
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		desc := strings.HasPrefix(part, "-")
+		part = strings.TrimPrefix(part, "-")
+
+		column, ok := UserSortColumns[part]
+		if !ok {
+			return nil, ErrInvalidSort
+		}
+		fields = append(fields, SortField{Column: column, Desc: desc})
+	}
+
+	return fields, nil
+}
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrCursorSort    = errors.New("cursor pagination supports a single sort field")
+	ErrCursorOffset  = errors.New("offset cannot be combined with cursor")
+)
+
+// Cursor — позиция в списке пользователей для постраничной выборки по ключу
+// (keyset): значение колонки сортировки и id граничной записи.
+// Backward означает выборку записей, предшествующих границе.
+type Cursor struct {
+	Sort     SortField
+	Value    string
+	ID       uint
+	Backward bool
+}
+
+type cursorPayload struct {
+	Column   string `json:"c"`
+	Desc     bool   `json:"d,omitempty"`
+	Value    string `json:"v"`
+	ID       uint   `json:"id"`
+	Backward bool   `json:"b,omitempty"`
+}
+
+// NewCursor строит курсор, указывающий на пользователя u при сортировке sort.
+func NewCursor(u domain.User, sort SortField, backward bool) Cursor {
+	return Cursor{
+		Sort:     sort,
+		Value:    userSortValue(u, sort.Column),
+		ID:       u.ID,
+		Backward: backward,
+	}
+}
+
+// Encode возвращает непрозрачное строковое представление курсора.
+func (c Cursor) Encode() string {
+	payload, _ := json.Marshal(cursorPayload{
+		Column:   c.Sort.Column,
+		Desc:     c.Sort.Desc,
+		Value:    c.Value,
+		ID:       c.ID,
+		Backward: c.Backward,
+	})
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// Arg возвращает значение колонки сортировки в виде, пригодном для сравнения в запросе.
+func (c Cursor) Arg() (interface{}, error) {
+	switch c.Sort.Column {
+	case "id", "age":
+		v, err := strconv.ParseUint(c.Value, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return uint(v), nil
+	case "created_at", "updated_at":
+		v, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return v, nil
+	default:
+		return c.Value, nil
+	}
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var payload cursorPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if _, ok := UserSortColumns[payload.Column]; !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	c := &Cursor{
+		Sort:     SortField{Column: payload.Column, Desc: payload.Desc},
+		Value:    payload.Value,
+		ID:       payload.ID,
+		Backward: payload.Backward,
+	}
+	if _, err := c.Arg(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func userSortValue(u domain.User, column string) string {
+	switch column {
+	case "name":
+		return u.Name
+	case "email":
+		return u.Email
+	case "age":
+		return strconv.FormatUint(uint64(u.Age), 10)
+	case "created_at":
+		return u.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		return u.UpdatedAt.Format(time.RFC3339Nano)
+	default:
+		return strconv.FormatUint(uint64(u.ID), 10)
+	}
+}
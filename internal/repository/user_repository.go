@@ -1,12 +1,43 @@
 package repository
 
-import "api_server/internal/domain"
+import (
+	"api_server/internal/domain"
+	"context"
+	"time"
+)
 
 type UserRepositoryInterface interface {
-	GetAll() ([]domain.User, error)
-	GetByID(id uint) (*domain.User, error)
-	GetByName(name string) (*domain.User, error)
-	Create(name, email string, age uint) (*domain.User, error)
-	Update(ID uint, name string, age uint) (*domain.User, error)
-	Delete(id uint) error
+	GetAll(ctx context.Context, query UserQuery) ([]domain.User, int64, error)
+	GetAllByCursor(ctx context.Context, query UserQuery, cursor *Cursor) ([]domain.User, bool, error)
+	GetByID(ctx context.Context, id uint) (*domain.User, error)
+	GetByName(ctx context.Context, name string) (*domain.User, error)
+	GetByEmail(ctx context.Context, email string) (*domain.User, error)
+	Create(ctx context.Context, name, email string, age uint, passwordHash string, role domain.Role) (*domain.User, error)
+	// CreateMany создаёт пользователей, пропуская тех, чей email уже занят,
+	// и возвращает, кто из них создан. Чтобы при ошибке не создавался никто,
+	// вызывайте его в транзакции (см. Transactor).
+	CreateMany(ctx context.Context, users []*domain.User) ([]bool, error)
+	// ExistingEmails возвращает занятые адреса из emails, в том числе занятые
+	// мягко удалёнными пользователями
+	ExistingEmails(ctx context.Context, emails []string) ([]string, error)
+	// Update и SetRole изменяют пользователя, только если его текущая версия
+	// равна version, и увеличивают версию; иначе возвращается service.ErrVersionMismatch
+	Update(ctx context.Context, ID uint, name string, age uint, version uint) (*domain.User, error)
+	SetRole(ctx context.Context, ID uint, role domain.Role, version uint) (*domain.User, error)
+	// SetPassword меняет хеш пароля с oldHash на newHash и отзывает сессии пользователя,
+	// увеличивая SessionVersion; если хеш или поколение сессий session уже изменились,
+	// возвращается service.ErrVersionMismatch
+	SetPassword(ctx context.Context, ID uint, oldHash, newHash string, session uint) (*domain.User, error)
+	// SetEmail меняет email пользователя на подтверждённый в verifiedAt; версия проверяется как в Update
+	SetEmail(ctx context.Context, ID uint, email string, verifiedAt time.Time, version uint) (*domain.User, error)
+	// Delete и Purge при version != 0 удаляют пользователя, только если версия совпадает.
+	// Delete и Restore, как и другие изменения, увеличивают версию.
+	Delete(ctx context.Context, id uint, version uint) error
+	// Restore отменяет мягкое удаление пользователя
+	Restore(ctx context.Context, id uint) (*domain.User, error)
+	// Purge удаляет пользователя безвозвратно, в том числе уже удалённого мягко
+	Purge(ctx context.Context, id uint, version uint) error
+	// PurgeDeletedBefore безвозвратно удаляет пользователей, удалённых мягко раньше before,
+	// и возвращает их ID
+	PurgeDeletedBefore(ctx context.Context, before time.Time) ([]uint, error)
 }
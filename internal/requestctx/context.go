@@ -0,0 +1,24 @@
+// Package requestctx передаёт сведения о HTTP-запросе в слои,
+// которые работают только с context.Context.
+package requestctx
+
+import "context"
+
+// Info — сведения о запросе, нужные за пределами HTTP-слоя
+type Info struct {
+	ID string
+	IP string
+}
+
+type infoKey struct{}
+
+// With возвращает контекст со сведениями о запросе
+func With(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, infoKey{}, info)
+}
+
+// From возвращает сведения о запросе; вне HTTP-запроса они пустые
+func From(ctx context.Context) Info {
+	info, _ := ctx.Value(infoKey{}).(Info)
+	return info
+}
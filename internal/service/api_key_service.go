@@ -0,0 +1,104 @@
+package service
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/domain"
+	"api_server/internal/repository"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// lastUsedPrecision — как часто обновляется время последнего использования ключа:
+// не чаще раза в минуту, чтобы не писать в базу на каждый запрос
+const lastUsedPrecision = time.Minute
+
+type APIKeyService struct {
+	repo   repository.APIKeyRepositoryInterface
+	policy *Policy
+	now    func() time.Time
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepositoryInterface, policy *Policy) *APIKeyService {
+	return &APIKeyService{repo: repo, policy: policy, now: time.Now}
+}
+
+// CreateKey создаёт ключ и возвращает его вместе с секретом.
+// Секрет больше нигде не хранится и показывается только один раз.
+func (s *APIKeyService) CreateKey(ctx context.Context, name string, scopes []string, expiresAt *time.Time) (*domain.APIKey, string, error) {
+	if err := auth.ValidateScopes(scopes); err != nil {
+		return nil, "", &ValidationError{
+			Err:    err,
+			Fields: []FieldViolation{{Field: "scopes", Rule: "oneof", Err: err}},
+		}
+	}
+	if err := s.policy.Authorize(ctx, ActionManageAPIKeys, 0); err != nil {
+		return nil, "", err
+	}
+
+	secret, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, "", wrapError(err)
+	}
+
+	scopes = slices.Compact(slices.Sorted(slices.Values(scopes)))
+	key := &domain.APIKey{
+		Name:       name,
+		Prefix:     prefix,
+		SecretHash: hash,
+		Scopes:     strings.Join(scopes, " "),
+		ExpiresAt:  expiresAt,
+	}
+	if err := s.repo.Create(ctx, key); err != nil {
+		return nil, "", wrapError(err)
+	}
+
+	return key, secret, nil
+}
+
+func (s *APIKeyService) GetKeys(ctx context.Context) ([]domain.APIKey, error) {
+	if err := s.policy.Authorize(ctx, ActionManageAPIKeys, 0); err != nil {
+		return nil, err
+	}
+	keys, err := s.repo.GetAll(ctx)
+	return keys, wrapError(err)
+}
+
+func (s *APIKeyService) RevokeKey(ctx context.Context, id uint) error {
+	if err := s.policy.Authorize(ctx, ActionManageAPIKeys, 0); err != nil {
+		return err
+	}
+	return wrapError(s.repo.Revoke(ctx, id, s.now()))
+}
+
+// Authenticate проверяет ключ и отмечает время его использования
+func (s *APIKeyService) Authenticate(ctx context.Context, rawKey string) (*domain.APIKey, error) {
+	prefix, err := auth.ParseAPIKey(rawKey)
+	if err != nil {
+		return nil, &UnauthorizedError{Err: err}
+	}
+
+	key, err := s.repo.GetByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return nil, &UnauthorizedError{Err: auth.ErrInvalidAPIKey}
+		}
+		return nil, wrapError(err)
+	}
+
+	now := s.now()
+	if !auth.CheckAPIKey(key.SecretHash, rawKey) || key.RevokedAt != nil || (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) {
+		return nil, &UnauthorizedError{Err: auth.ErrInvalidAPIKey}
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedPrecision {
+		if err := s.repo.TouchLastUsed(ctx, key.ID, now); err != nil {
+			return nil, wrapError(err)
+		}
+		key.LastUsedAt = &now
+	}
+
+	return key, nil
+}
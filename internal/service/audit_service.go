@@ -0,0 +1,96 @@
+package service
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/domain"
+	"api_server/internal/repository"
+	"api_server/internal/requestctx"
+	"context"
+	"strconv"
+)
+
+type AuditService struct {
+	repo   repository.AuditRepositoryInterface
+	policy *Policy
+}
+
+func NewAuditService(repo repository.AuditRepositoryInterface, policy *Policy) *AuditService {
+	return &AuditService{repo: repo, policy: policy}
+}
+
+func (s *AuditService) GetRecords(ctx context.Context, query repository.AuditQuery) ([]domain.AuditRecord, int64, error) {
+	if err := s.policy.Authorize(ctx, ActionReadAudit, 0); err != nil {
+		return nil, 0, err
+	}
+	records, total, err := s.repo.GetAll(ctx, query)
+	return records, total, wrapError(err)
+}
+
+// record пишет в журнал изменение пользователя userID. Субъект, ID запроса
+// и IP берутся из контекста; before и after — состояние до и после действия.
+// Обновление, которое ничего не изменило, не записывается.
+func (s *AuditService) record(ctx context.Context, action string, userID uint, before, after *domain.User) error {
+	changes := diffUsers(before, after)
+	if action == domain.AuditUserUpdate && changes == nil {
+		return nil
+	}
+
+	info := requestctx.From(ctx)
+	return wrapError(s.repo.Create(ctx, &domain.AuditRecord{
+		Actor:     Actor(ctx),
+		Action:    action,
+		UserID:    userID,
+		Changes:   changes,
+		RequestID: info.ID,
+		IP:        info.IP,
+	}))
+}
+
+// Actor возвращает субъекта запроса в виде, в котором он пишется в журнал аудита
+func Actor(ctx context.Context) string {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	switch {
+	case !ok:
+		return "system"
+	case principal.APIKeyID != 0:
+		return "api-key:" + strconv.FormatUint(uint64(principal.APIKeyID), 10)
+	default:
+		return "user:" + strconv.FormatUint(uint64(principal.UserID), 10)
+	}
+}
+
+// auditedFields — поля пользователя, изменения которых попадают в журнал
+var auditedFields = []string{"name", "age", "email", "email_verified", "role"}
+
+// diffUsers возвращает изменившиеся поля пользователя; nil вместо before или after
+// означает, что пользователя до или после действия не было
+func diffUsers(before, after *domain.User) map[string]domain.AuditChange {
+	from, to := auditedValues(before), auditedValues(after)
+
+	changes := make(map[string]domain.AuditChange)
+	for _, field := range auditedFields {
+		f, hasFrom := from[field]
+		t, hasTo := to[field]
+		if (!hasFrom && !hasTo) || (hasFrom && hasTo && f == t) {
+			continue
+		}
+		changes[field] = domain.AuditChange{From: f, To: t}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+func auditedValues(u *domain.User) map[string]interface{} {
+	if u == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"name":           u.Name,
+		"age":            u.Age,
+		"email":          u.Email,
+		"email_verified": u.EmailVerifiedAt != nil,
+		"role":           u.Role,
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/repository"
+	"context"
+	"errors"
+)
+
+// dummyPasswordHash сравнивается с паролем, когда пользователь не найден,
+// чтобы время ответа не выдавало существование email
+const dummyPasswordHash = "$2a$10$PIkYaq45SOHK5rITMuXvkej306sYnPnhjExjnrYzUKuvur/7pYHfa"
+
+type AuthService struct {
+	repo   repository.UserRepositoryInterface
+	tokens *auth.TokenManager
+}
+
+func NewAuthService(repo repository.UserRepositoryInterface, tokens *auth.TokenManager) *AuthService {
+	return &AuthService{repo: repo, tokens: tokens}
+}
+
+// Login проверяет email и пароль и выдаёт пару токенов
+func (s *AuthService) Login(ctx context.Context, email, password string) (auth.TokenPair, error) {
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			auth.CheckPassword(dummyPasswordHash, password)
+			return auth.TokenPair{}, &UnauthorizedError{Err: ErrInvalidCredentials}
+		}
+		return auth.TokenPair{}, wrapError(err)
+	}
+	if user.PasswordHash == "" || !auth.CheckPassword(user.PasswordHash, password) {
+		return auth.TokenPair{}, &UnauthorizedError{Err: ErrInvalidCredentials}
+	}
+
+	tokens, err := s.tokens.Issue(user.ID, user.SessionVersion)
+	return tokens, wrapError(err)
+}
+
+// Refresh выдаёт новую пару токенов по действующему refresh-токену
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (auth.TokenPair, error) {
+	claims, err := s.tokens.Parse(refreshToken, auth.TokenTypeRefresh)
+	if err != nil {
+		return auth.TokenPair{}, &UnauthorizedError{Err: err}
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		return auth.TokenPair{}, &UnauthorizedError{Err: err}
+	}
+
+	// Токены удалённого пользователя больше не обновляются
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return auth.TokenPair{}, &UnauthorizedError{Err: auth.ErrInvalidToken}
+		}
+		return auth.TokenPair{}, wrapError(err)
+	}
+	// Сессии, отозванные после выдачи токена (например, сбросом пароля)
+	if claims.Session != user.SessionVersion {
+		return auth.TokenPair{}, &UnauthorizedError{Err: auth.ErrInvalidToken}
+	}
+
+	tokens, err := s.tokens.Issue(user.ID, user.SessionVersion)
+	return tokens, wrapError(err)
+}
@@ -1,10 +1,178 @@
 package service
 
-import "errors"
+import (
+	"context"
+	"errors"
+)
 
+// Тексты ошибок предназначены для логов; сообщения для клиентов API
+// берутся из каталога на языке запроса.
 var (
-	ErrNotFound         = errors.New("Пользователь не найден")
-	ErrInvalidAge       = errors.New("Возраст пользователя не может быть менее 14 лет")
-	ErrIDNotTransmitted = errors.New("ID пользователя не передан")
-	ErrIDNotValid       = errors.New("Некорректный ID пользователя")
+	ErrNotFound         = errors.New("user not found")
+	ErrInvalidAge       = errors.New("user age must be at least 14")
+	ErrIDNotTransmitted = errors.New("user ID is missing")
+	ErrIDNotValid       = errors.New("invalid user ID")
+	ErrEmailTaken       = errors.New("user with this email already exists")
+	ErrInvalidRole      = errors.New("unknown user role")
+	ErrPasswordTooLong  = errors.New("password must be at most 72 bytes")
+	ErrNotDeleted       = errors.New("user is not deleted")
+	ErrVersionNotFound  = errors.New("user version not found")
+	ErrVersionMismatch  = errors.New("user was modified by another request")
+
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrForbidden          = errors.New("forbidden")
+
+	ErrAPIKeyNotFound = errors.New("API key not found")
+
+	ErrIdempotencyKeyNotFound   = errors.New("idempotency key not found")
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was used with a different request")
+	ErrIdempotencyKeyInProgress = errors.New("request with this idempotency key is in progress")
+
+	ErrEmailAlreadyVerified      = errors.New("email is already verified")
+	ErrInvalidVerificationToken  = errors.New("invalid email verification token")
+	ErrEmailVerificationDisabled = errors.New("email verification is not configured")
+	ErrInvalidResetToken         = errors.New("invalid password reset token")
+	ErrPasswordResetDisabled     = errors.New("password reset is not configured")
+
+	ErrExportNotFound = errors.New("export job not found")
+	ErrExportNotReady = errors.New("export job is not completed")
 )
+
+// Типизированные ошибки сервиса. Каждая оборачивает причину (как правило,
+// одну из ошибок выше), поэтому errors.Is(err, ErrNotFound) продолжает работать.
+
+type NotFoundError struct {
+	Err error
+}
+
+func (e *NotFoundError) Error() string { return e.Err.Error() }
+func (e *NotFoundError) Unwrap() error { return e.Err }
+
+// FieldViolation описывает нарушение правила валидации для поля Field
+type FieldViolation struct {
+	Field string
+	Rule  string
+	Err   error
+}
+
+type ValidationError struct {
+	Err    error
+	Fields []FieldViolation
+}
+
+func (e *ValidationError) Error() string { return e.Err.Error() }
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+type ConflictError struct {
+	Err error
+}
+
+func (e *ConflictError) Error() string { return e.Err.Error() }
+func (e *ConflictError) Unwrap() error { return e.Err }
+
+type PreconditionFailedError struct {
+	Err error
+}
+
+func (e *PreconditionFailedError) Error() string { return e.Err.Error() }
+func (e *PreconditionFailedError) Unwrap() error { return e.Err }
+
+// UnprocessableError — запрос корректен, но не может быть выполнен в текущем состоянии
+type UnprocessableError struct {
+	Err error
+}
+
+func (e *UnprocessableError) Error() string { return e.Err.Error() }
+func (e *UnprocessableError) Unwrap() error { return e.Err }
+
+type UnauthorizedError struct {
+	Err error
+}
+
+func (e *UnauthorizedError) Error() string { return e.Err.Error() }
+func (e *UnauthorizedError) Unwrap() error { return e.Err }
+
+type ForbiddenError struct {
+	Err error
+}
+
+func (e *ForbiddenError) Error() string { return e.Err.Error() }
+func (e *ForbiddenError) Unwrap() error { return e.Err }
+
+type InternalError struct {
+	Err error
+}
+
+func (e *InternalError) Error() string { return e.Err.Error() }
+func (e *InternalError) Unwrap() error { return e.Err }
+
+// wrapError приводит ошибку репозитория к типизированной ошибке сервиса
+func wrapError(err error) error {
+	var (
+		notFound      *NotFoundError
+		validation    *ValidationError
+		conflict      *ConflictError
+		precondition  *PreconditionFailedError
+		unprocessable *UnprocessableError
+		unauthorized  *UnauthorizedError
+		forbidden     *ForbiddenError
+		internal      *InternalError
+	)
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &notFound), errors.As(err, &validation), errors.As(err, &conflict),
+		errors.As(err, &precondition), errors.As(err, &unprocessable), errors.As(err, &unauthorized), errors.As(err, &forbidden),
+		errors.As(err, &internal):
+		return err
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrAPIKeyNotFound), errors.Is(err, ErrVersionNotFound),
+		errors.Is(err, ErrExportNotFound):
+		return &NotFoundError{Err: err}
+	case errors.Is(err, ErrEmailTaken), errors.Is(err, ErrNotDeleted), errors.Is(err, ErrIdempotencyKeyInProgress),
+		errors.Is(err, ErrExportNotReady), errors.Is(err, ErrEmailAlreadyVerified):
+		return &ConflictError{Err: err}
+	case errors.Is(err, ErrVersionMismatch):
+		return &PreconditionFailedError{Err: err}
+	default:
+		return &InternalError{Err: err}
+	}
+}
+
+// ErrorType возвращает вид ошибки сервиса для метрик и логов: not_found,
+// validation, conflict, precondition_failed, unprocessable, unauthorized,
+// forbidden, canceled, timeout или internal
+func ErrorType(err error) string {
+	var (
+		notFound      *NotFoundError
+		validation    *ValidationError
+		conflict      *ConflictError
+		precondition  *PreconditionFailedError
+		unprocessable *UnprocessableError
+		unauthorized  *UnauthorizedError
+		forbidden     *ForbiddenError
+	)
+
+	switch err = wrapError(err); {
+	case errors.As(err, &notFound):
+		return "not_found"
+	case errors.As(err, &validation):
+		return "validation"
+	case errors.As(err, &conflict):
+		return "conflict"
+	case errors.As(err, &precondition):
+		return "precondition_failed"
+	case errors.As(err, &unprocessable):
+		return "unprocessable"
+	case errors.As(err, &unauthorized):
+		return "unauthorized"
+	case errors.As(err, &forbidden):
+		return "forbidden"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "internal"
+	}
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"api_server/internal/domain"
+	"api_server/internal/export"
+	"api_server/internal/repository"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultExportTTL — сколько хранится готовый файл фоновой выгрузки
+const DefaultExportTTL = 24 * time.Hour
+
+// ExportService выполняет выгрузки пользователей в фоне (см. Run) и хранит
+// готовые файлы в каталоге dir. Задания хранятся в базе, поэтому переживают
+// перезапуск сервера: прерванные задания выполняются заново.
+type ExportService struct {
+	repo   repository.ExportJobRepositoryInterface
+	users  *UserService
+	policy *Policy
+	dir    string
+	ttl    time.Duration
+	// wake будит Run, когда появляется новое задание
+	wake chan struct{}
+	now  func() time.Time
+}
+
+func NewExportService(
+	repo repository.ExportJobRepositoryInterface,
+	users *UserService,
+	policy *Policy,
+	dir string,
+	ttl time.Duration,
+) *ExportService {
+	if ttl <= 0 {
+		ttl = DefaultExportTTL
+	}
+	return &ExportService{
+		repo:   repo,
+		users:  users,
+		policy: policy,
+		dir:    dir,
+		ttl:    ttl,
+		wake:   make(chan struct{}, 1),
+		now:    time.Now,
+	}
+}
+
+// StartExport ставит в очередь выгрузку пользователей, подходящих под query,
+// в формате format. Права проверяются при постановке в очередь.
+func (s *ExportService) StartExport(ctx context.Context, format string, columns []string, query repository.UserQuery) (*domain.ExportJob, error) {
+	if err := s.policy.Authorize(ctx, ActionListUsers, 0); err != nil {
+		return nil, err
+	}
+	if export.ContentType(format) == "" {
+		return nil, &ValidationError{
+			Err:    export.ErrUnknownFormat,
+			Fields: []FieldViolation{{Field: "format", Rule: "oneof", Err: export.ErrUnknownFormat}},
+		}
+	}
+	rawQuery, err := json.Marshal(query)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	job := &domain.ExportJob{
+		Actor:   Actor(ctx),
+		Status:  domain.ExportPending,
+		Format:  format,
+		Columns: columns,
+		Query:   rawQuery,
+	}
+	if err := s.repo.Create(ctx, job); err != nil {
+		return nil, wrapError(err)
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// GetExport возвращает задание, запущенное тем же субъектом; чужие задания
+// неотличимы от несуществующих
+func (s *ExportService) GetExport(ctx context.Context, id uint) (*domain.ExportJob, error) {
+	job, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if job.Actor != Actor(ctx) {
+		return nil, &NotFoundError{Err: ErrExportNotFound}
+	}
+	return job, nil
+}
+
+// ExportFile возвращает завершённое задание с путём к файлу выгрузки
+func (s *ExportService) ExportFile(ctx context.Context, id uint) (*domain.ExportJob, error) {
+	job, err := s.GetExport(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != domain.ExportCompleted {
+		return nil, &ConflictError{Err: ErrExportNotReady}
+	}
+	if job.ExpiresAt != nil && s.now().After(*job.ExpiresAt) {
+		return nil, &NotFoundError{Err: ErrExportNotFound}
+	}
+	return job, nil
+}
+
+// Run выполняет задания по очереди, пока не отменён ctx, и раз в interval
+// удаляет задания и файлы с истёкшим сроком хранения.
+// Одновременно должен работать только один Run на базу.
+func (s *ExportService) Run(ctx context.Context, interval time.Duration) {
+	if err := os.MkdirAll(s.dir, 0o750); err != nil {
+		slog.ErrorContext(ctx, "create export directory", "error", err)
+		return
+	}
+	if requeued, err := s.repo.RequeueRunning(ctx); err != nil {
+		slog.ErrorContext(ctx, "requeue export jobs", "error", err)
+	} else if requeued > 0 {
+		slog.InfoContext(ctx, "requeued interrupted export jobs", "count", requeued)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.runPending(ctx)
+		s.deleteExpired(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ExportService) runPending(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := s.repo.ClaimPending(ctx)
+		if errors.Is(err, ErrExportNotFound) {
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "claim export job", "error", err)
+			return
+		}
+
+		err = s.run(ctx, job)
+		if ctx.Err() != nil {
+			// Сервер останавливается: задание будет выполнено заново после запуска
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "export job failed", "job_id", job.ID, "error", err)
+			now := s.now()
+			expires := now.Add(s.ttl)
+			job.Status, job.Error = domain.ExportFailed, err.Error()
+			job.CompletedAt, job.ExpiresAt = &now, &expires
+			if err := s.repo.Update(ctx, job); err != nil {
+				slog.ErrorContext(ctx, "update export job", "job_id", job.ID, "error", err)
+			}
+		}
+	}
+}
+
+// run записывает выгрузку во временный файл и по завершении переименовывает его,
+// чтобы по пути задания никогда не лежал недописанный файл
+func (s *ExportService) run(ctx context.Context, job *domain.ExportJob) error {
+	var query repository.UserQuery
+	if err := json.Unmarshal(job.Query, &query); err != nil {
+		return fmt.Errorf("decode query: %w", err)
+	}
+	_, total, err := s.users.GetUsers(ctx, repository.UserQuery{Filter: query.Filter, Limit: 1})
+	if err != nil {
+		return err
+	}
+	job.Total, job.Processed = total, 0
+	if err := s.repo.Update(ctx, job); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, fmt.Sprintf("export-%d-*.tmp", job.ID))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	w := bufio.NewWriter(tmp)
+	encoder, err := export.NewEncoder(job.Format, w, job.Columns)
+	if err != nil {
+		return err
+	}
+	err = s.users.ExportUsers(ctx, query, ExportBatchSize, func(users []domain.User) error {
+		if err := encoder.Encode(users); err != nil {
+			return err
+		}
+		job.Processed += int64(len(users))
+		return s.repo.Update(ctx, job)
+	})
+	if err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, fmt.Sprintf("export-%d.%s", job.ID, job.Format))
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	now := s.now()
+	expires := now.Add(s.ttl)
+	job.Status, job.FilePath, job.Size = domain.ExportCompleted, path, info.Size()
+	job.CompletedAt, job.ExpiresAt = &now, &expires
+	return s.repo.Update(ctx, job)
+}
+
+func (s *ExportService) deleteExpired(ctx context.Context) {
+	jobs, err := s.repo.DeleteExpired(ctx, s.now())
+	if err != nil {
+		slog.ErrorContext(ctx, "delete expired export jobs", "error", err)
+		return
+	}
+	for _, job := range jobs {
+		if job.FilePath == "" {
+			continue
+		}
+		if err := os.Remove(job.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.ErrorContext(ctx, "remove export file", "path", job.FilePath, "error", err)
+		}
+	}
+}
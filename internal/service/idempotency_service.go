@@ -0,0 +1,104 @@
+package service
+
+import (
+	"api_server/internal/domain"
+	"api_server/internal/repository"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// DefaultIdempotencyTTL — сколько хранится ответ на запрос с Idempotency-Key
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// DefaultIdempotencyLockTimeout — через сколько незавершённый запрос с ключом
+// считается брошенным (например, процесс упал, не освободив ключ)
+const DefaultIdempotencyLockTimeout = time.Minute
+
+type IdempotencyService struct {
+	repo repository.IdempotencyRepositoryInterface
+	ttl  time.Duration
+	lock time.Duration
+	now  func() time.Time
+}
+
+func NewIdempotencyService(repo repository.IdempotencyRepositoryInterface, ttl, lockTimeout time.Duration) *IdempotencyService {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	if lockTimeout <= 0 {
+		lockTimeout = DefaultIdempotencyLockTimeout
+	}
+	return &IdempotencyService{repo: repo, ttl: ttl, lock: lockTimeout, now: time.Now}
+}
+
+// Begin резервирует ключ key для запроса с хешем requestHash. Если ответ на
+// такой же запрос уже сохранён, он возвращается с replay == true. Повтор ключа
+// с другим запросом даёт UnprocessableError, а пока первый запрос выполняется —
+// ConflictError. Незавершённый ключ с истёкшим LockedUntil занимается заново.
+func (s *IdempotencyService) Begin(ctx context.Context, key, requestHash string) (record *domain.IdempotencyKey, replay bool, err error) {
+	actor := Actor(ctx)
+	now := s.now()
+
+	existing, err := s.repo.Get(ctx, actor, key)
+	switch {
+	case errors.Is(err, ErrIdempotencyKeyNotFound):
+	case err != nil:
+		return nil, false, wrapError(err)
+	case now.After(existing.ExpiresAt), existing.StatusCode == 0 && now.After(existing.LockedUntil):
+		// Удаление по ID: если ключ уже занял конкурент, Create ниже вернёт конфликт
+		if err := s.repo.Delete(ctx, existing.ID); err != nil {
+			return nil, false, wrapError(err)
+		}
+	case existing.RequestHash != requestHash:
+		return nil, false, &UnprocessableError{Err: ErrIdempotencyKeyReused}
+	case existing.StatusCode == 0:
+		return nil, false, &ConflictError{Err: ErrIdempotencyKeyInProgress}
+	default:
+		return existing, true, nil
+	}
+
+	record = &domain.IdempotencyKey{
+		Actor:       actor,
+		Key:         key,
+		RequestHash: requestHash,
+		LockedUntil: now.Add(s.lock),
+		ExpiresAt:   now.Add(s.ttl),
+	}
+	if err := s.repo.Create(ctx, record); err != nil {
+		return nil, false, wrapError(err)
+	}
+	return record, false, nil
+}
+
+// Complete сохраняет ответ на запрос, начатый в Begin
+func (s *IdempotencyService) Complete(ctx context.Context, record *domain.IdempotencyKey, status int, headers map[string]string, body []byte) error {
+	record.StatusCode = status
+	record.Headers = headers
+	record.Body = body
+	return wrapError(s.repo.Complete(ctx, record))
+}
+
+// Abort освобождает ключ, чтобы запрос можно было повторить
+func (s *IdempotencyService) Abort(ctx context.Context, record *domain.IdempotencyKey) error {
+	return wrapError(s.repo.Delete(ctx, record.ID))
+}
+
+// RunCleanup раз в interval удаляет просроченные ключи, пока не отменён ctx
+func (s *IdempotencyService) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.repo.DeleteExpired(ctx, s.now()); err != nil {
+			slog.ErrorContext(ctx, "delete expired idempotency keys", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/domain"
+	"api_server/internal/repository"
+	"context"
+	"errors"
+	"slices"
+)
+
+// Action — действие, доступ к которому проверяет Policy
+type Action string
+
+const (
+	ActionReadUser      Action = "user:read"
+	ActionListUsers     Action = "user:list"
+	ActionCreateUser    Action = "user:create"
+	ActionUpdateUser    Action = "user:update"
+	ActionDeleteUser    Action = "user:delete"
+	ActionRestoreUser   Action = "user:restore"
+	ActionPurgeUser     Action = "user:purge"
+	ActionChangeRole    Action = "user:change-role"
+	ActionChangeEmail   Action = "user:change-email"
+	ActionManageAPIKeys Action = "api-keys:manage"
+	ActionReadAudit     Action = "audit:read"
+)
+
+// permissions — действия, разрешённые роли над любыми пользователями
+var permissions = map[domain.Role][]Action{
+	domain.RoleAdmin: {
+		ActionReadUser, ActionListUsers, ActionCreateUser, ActionUpdateUser,
+		ActionDeleteUser, ActionRestoreUser, ActionPurgeUser, ActionChangeRole, ActionChangeEmail,
+		ActionManageAPIKeys, ActionReadAudit,
+	},
+	domain.RoleManager: {ActionReadUser, ActionListUsers, ActionRestoreUser},
+}
+
+// ownPermissions — действия, которые любой пользователь может выполнять над своей записью
+var ownPermissions = []Action{ActionReadUser, ActionUpdateUser, ActionChangeEmail}
+
+// userOnlyActions — действия, которые нельзя выполнить API-ключом ни с какими правами:
+// смена роли раздаёт права администратора, а смена email меняет адрес для входа и сброса пароля
+var userOnlyActions = []Action{ActionChangeRole, ActionChangeEmail}
+
+// Policy решает, может ли субъект запроса выполнить действие.
+// Роль пользователя читается из базы на каждую проверку, поэтому её смена
+// действует сразу, без перевыпуска токенов. API-ключи ограничиваются правами
+// (см. api.RequireScope) и не могут выполнять действия из userOnlyActions,
+// а вызовы без субъекта в контексте — внутренние (например, создание
+// администратора при запуске) и разрешены.
+type Policy struct {
+	users repository.UserRepositoryInterface
+}
+
+func NewPolicy(users repository.UserRepositoryInterface) *Policy {
+	return &Policy{users: users}
+}
+
+// Authorize возвращает ForbiddenError, если действие над пользователем
+// targetID запрещено. Для действий не над конкретным пользователем targetID равен 0.
+func (p *Policy) Authorize(ctx context.Context, action Action, targetID uint) error {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	if principal.APIKeyID != 0 {
+		if slices.Contains(userOnlyActions, action) {
+			return &ForbiddenError{Err: ErrForbidden}
+		}
+		return nil
+	}
+
+	caller, err := p.users.GetByID(ctx, principal.UserID)
+	if err != nil {
+		// Токен пользователя, удалённого после входа, больше не действителен
+		if errors.Is(err, ErrNotFound) {
+			return &UnauthorizedError{Err: auth.ErrInvalidToken}
+		}
+		return wrapError(err)
+	}
+	// Как и токен сессии, отозванной после входа
+	if principal.Session != caller.SessionVersion {
+		return &UnauthorizedError{Err: auth.ErrInvalidToken}
+	}
+
+	if slices.Contains(permissions[caller.Role], action) {
+		return nil
+	}
+	if targetID != 0 && targetID == caller.ID && slices.Contains(ownPermissions, action) {
+		return nil
+	}
+	return &ForbiddenError{Err: ErrForbidden}
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/domain"
+	"api_server/internal/mail"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// EmailVerification настраивает письма, которыми пользователи подтверждают email
+type EmailVerification struct {
+	Tokens *auth.TokenManager
+	Mailer mail.Mailer
+	// URL — адрес подтверждения; токен передаётся в нём параметром token
+	URL string
+	// TTL — срок действия ссылки; по умолчанию auth.DefaultEmailVerificationTTL
+	TTL time.Duration
+}
+
+// RequestEmailChange отправляет на адрес email ссылку для подтверждения.
+// Email пользователя меняется, только когда он перейдёт по ссылке (см. VerifyEmail).
+// Для текущего неподтверждённого адреса письмо отправляется повторно.
+// Сменить email может сам пользователь или администратор, но не API-ключ.
+func (s *UserService) RequestEmailChange(ctx context.Context, ID uint, email string) (err error) {
+	defer s.observe("request_email_change", &err)
+	if err := s.policy.Authorize(ctx, ActionChangeEmail, ID); err != nil {
+		return err
+	}
+	user, err := s.getForUpdate(ctx, ID, 0)
+	if err != nil {
+		return err
+	}
+
+	if email == user.Email {
+		if user.EmailVerifiedAt != nil {
+			return &ConflictError{Err: ErrEmailAlreadyVerified}
+		}
+	} else {
+		taken, err := s.repo.ExistingEmails(ctx, []string{email})
+		if err != nil {
+			return wrapError(err)
+		}
+		if len(taken) > 0 {
+			return &ConflictError{Err: ErrEmailTaken}
+		}
+	}
+	return s.sendVerification(ctx, user, email)
+}
+
+// VerifyEmail подтверждает адрес по токену из письма: у нового пользователя
+// отмечает email подтверждённым, при смене адреса — меняет email.
+// Токен перестаёт действовать, как только email пользователя изменится.
+func (s *UserService) VerifyEmail(ctx context.Context, token string) (_ *domain.User, err error) {
+	defer s.observe("verify_email", &err)
+	if s.verification == nil {
+		return nil, &InternalError{Err: ErrEmailVerificationDisabled}
+	}
+	claims, err := s.verification.Tokens.Parse(token, auth.TokenTypeEmailVerification)
+	if err != nil {
+		return nil, invalidToken(err, ErrInvalidVerificationToken)
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		return nil, invalidToken(err, ErrInvalidVerificationToken)
+	}
+	user, err := s.repo.GetByID(ctx, userID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, invalidToken(ErrInvalidVerificationToken, ErrInvalidVerificationToken)
+	}
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if user.Email != claims.EmailFrom {
+		return nil, invalidToken(ErrInvalidVerificationToken, ErrInvalidVerificationToken)
+	}
+	if user.Email == claims.Email && user.EmailVerifiedAt != nil {
+		return user, nil
+	}
+
+	// Ссылку открывает сам пользователь: изменение записывается от его имени
+	ctx = auth.WithPrincipal(ctx, auth.Principal{UserID: user.ID, Session: user.SessionVersion})
+	return s.update(ctx, user.ID, user.Version, func(ctx context.Context, before *domain.User) (*domain.User, error) {
+		return s.repo.SetEmail(ctx, user.ID, claims.Email, time.Now(), before.Version)
+	})
+}
+
+// sendVerification отправляет на адрес email ссылку, подтверждающую его для user
+func (s *UserService) sendVerification(ctx context.Context, user *domain.User, email string) error {
+	if s.verification == nil {
+		return &InternalError{Err: ErrEmailVerificationDisabled}
+	}
+	token, err := s.verification.Tokens.IssueEmailVerification(user.ID, user.Email, email, s.verification.TTL)
+	if err != nil {
+		return wrapError(err)
+	}
+	link, err := tokenLink(s.verification.URL, token)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	err = s.verification.Mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Подтверждение email / Email confirmation",
+		Body: fmt.Sprintf(
+			"Чтобы подтвердить адрес %[1]s, перейдите по ссылке:\n%[2]s\n\n"+
+				"To confirm %[1]s, open the link:\n%[2]s\n",
+			email, link,
+		),
+	})
+	return wrapError(err)
+}
+
+// tokenLink добавляет к адресу base параметр token
+func tokenLink(base, token string) (string, error) {
+	link, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
+
+// invalidToken — ошибка валидации поля token: истёкший токен сообщает об этом,
+// остальные причины сводятся к invalid
+func invalidToken(err, invalid error) error {
+	if !errors.Is(err, auth.ErrTokenExpired) {
+		err = invalid
+	}
+	return &ValidationError{
+		Err:    err,
+		Fields: []FieldViolation{{Field: "token", Rule: "valid", Err: err}},
+	}
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"api_server/internal/domain"
+	"api_server/internal/repository"
+	"context"
+)
+
+// ExportBatchSize — сколько пользователей выбирается из базы за один запрос при выгрузке
+const ExportBatchSize = 500
+
+// ExportUsers выбирает всех пользователей, подходящих под query, пачками
+// по batchSize записей и передаёт каждую пачку в fn. Пачки выбираются по ключу
+// (см. GetUsersByCursor), поэтому в памяти одновременно находится не больше одной пачки.
+// Учитывается только первое поле сортировки; Limit и Offset из query игнорируются.
+// Ошибка fn прерывает выборку и возвращается как есть.
+func (s *UserService) ExportUsers(ctx context.Context, query repository.UserQuery, batchSize int, fn func([]domain.User) error) (err error) {
+	defer s.observe("export_users", &err)
+	if err := s.policy.Authorize(ctx, ActionListUsers, 0); err != nil {
+		return err
+	}
+
+	sort := repository.SortField{Column: "id"}
+	if len(query.Sort) > 0 {
+		sort = query.Sort[0]
+	}
+	query.Sort = []repository.SortField{sort}
+	query.Limit, query.Offset = batchSize, 0
+
+	var cursor *repository.Cursor
+	for {
+		users, hasMore, err := s.repo.GetAllByCursor(ctx, query, cursor)
+		if err != nil {
+			return wrapError(err)
+		}
+		if len(users) > 0 {
+			if err := fn(users); err != nil {
+				return err
+			}
+		}
+		if !hasMore || len(users) == 0 {
+			return nil
+		}
+		next := repository.NewCursor(users[len(users)-1], sort, false)
+		cursor = &next
+	}
+}
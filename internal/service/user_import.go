@@ -0,0 +1,252 @@
+package service
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/domain"
+	"context"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+type ImportStatus string
+
+const (
+	// ImportCreated — пользователь создан
+	ImportCreated ImportStatus = "created"
+	// ImportValid — строка корректна, но пользователь не создан: пробный импорт
+	// или атомарный импорт, отменённый из-за ошибок в других строках
+	ImportValid ImportStatus = "valid"
+	// ImportSkipped — email уже занят или повторяется в файле
+	ImportSkipped ImportStatus = "skipped"
+	// ImportInvalid — строка не прошла разбор или валидацию
+	ImportInvalid ImportStatus = "invalid"
+	// ImportFailed — строка корректна, но пользователя не удалось создать
+	// из-за внутренней ошибки; такую строку можно импортировать повторно
+	ImportFailed ImportStatus = "failed"
+)
+
+// ImportRow — строка файла импорта. Err заполняется, если строка
+// не прошла разбор или валидацию до вызова сервиса.
+type ImportRow struct {
+	Line     int
+	Name     string
+	Email    string
+	Age      uint
+	Password string
+	Role     domain.Role
+	Err      error
+}
+
+type ImportOptions struct {
+	// DryRun — только проверить строки, ничего не создавая
+	DryRun bool
+	// Atomic — создать всех пользователей в одной транзакции и только если
+	// в файле нет некорректных строк; иначе каждая корректная строка создаётся
+	// в своей транзакции, и ошибка одной строки не отменяет остальные
+	Atomic bool
+}
+
+type ImportRowResult struct {
+	Line   int
+	Email  string
+	Status ImportStatus
+	UserID uint
+	// Err — причина, по которой строка пропущена или отклонена
+	Err error
+}
+
+type ImportReport struct {
+	// Committed сообщает, были ли изменения записаны в базу
+	Committed bool
+	Rows      []ImportRowResult
+}
+
+// Count возвращает число строк со статусом status
+func (r *ImportReport) Count(status ImportStatus) int {
+	n := 0
+	for _, row := range r.Rows {
+		if row.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// ImportUsers создаёт пользователей из строк rows по тем же правилам, что и CreateUser.
+// Пользователи с уже занятым email пропускаются. Ошибка возвращается, только если
+// импорт не удалось выполнить целиком; проблемы отдельных строк, в том числе
+// ошибки БД при независимом создании строк, описаны в отчёте.
+func (s *UserService) ImportUsers(ctx context.Context, rows []ImportRow, opts ImportOptions) (_ *ImportReport, err error) {
+	defer s.observe("import_users", &err)
+	if err := s.policy.Authorize(ctx, ActionCreateUser, 0); err != nil {
+		return nil, err
+	}
+	canChangeRole := s.policy.Authorize(ctx, ActionChangeRole, 0) == nil
+
+	report := &ImportReport{Rows: make([]ImportRowResult, len(rows))}
+	var pending []int
+	seen := make(map[string]bool, len(rows))
+	for i, row := range rows {
+		result := &report.Rows[i]
+		*result = ImportRowResult{Line: row.Line, Email: row.Email, Status: ImportValid, Err: row.Err}
+		if result.Err == nil {
+			result.Err = validateImportRow(&rows[i], canChangeRole)
+		}
+		switch {
+		case result.Err != nil:
+			result.Status = ImportInvalid
+		case seen[row.Email]:
+			result.Status, result.Err = ImportSkipped, &ConflictError{Err: ErrEmailTaken}
+		default:
+			seen[row.Email] = true
+			pending = append(pending, i)
+		}
+	}
+
+	emails := make([]string, len(pending))
+	for j, i := range pending {
+		emails[j] = rows[i].Email
+	}
+	existing, err := s.repo.ExistingEmails(ctx, emails)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	taken := make(map[string]bool, len(existing))
+	for _, email := range existing {
+		taken[email] = true
+	}
+	pending = slices.DeleteFunc(pending, func(i int) bool {
+		if !taken[rows[i].Email] {
+			return false
+		}
+		report.Rows[i].Status, report.Rows[i].Err = ImportSkipped, &ConflictError{Err: ErrEmailTaken}
+		return true
+	})
+
+	if opts.DryRun || (opts.Atomic && report.Count(ImportInvalid) > 0) {
+		return report, nil
+	}
+
+	passwords := make([]string, len(pending))
+	for j, i := range pending {
+		passwords[j] = rows[i].Password
+	}
+	hashes, err := hashPasswords(ctx, passwords)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	users := make([]*domain.User, len(pending))
+	for j, i := range pending {
+		row := rows[i]
+		users[j] = &domain.User{Name: row.Name, Email: row.Email, Age: row.Age, Role: row.Role, PasswordHash: hashes[j]}
+	}
+
+	if opts.Atomic {
+		err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+			return s.createImported(ctx, users)
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for j := range users {
+			err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+				return s.createImported(ctx, users[j:j+1])
+			})
+			if err != nil {
+				report.Rows[pending[j]].Status, report.Rows[pending[j]].Err = ImportFailed, wrapError(err)
+			}
+		}
+	}
+
+	for j, i := range pending {
+		result := &report.Rows[i]
+		switch {
+		case result.Status == ImportFailed:
+		case users[j].ID == 0:
+			// Email заняли между проверкой и вставкой
+			result.Status, result.Err = ImportSkipped, &ConflictError{Err: ErrEmailTaken}
+		default:
+			result.Status, result.UserID = ImportCreated, users[j].ID
+			report.Committed = true
+		}
+	}
+	return report, nil
+}
+
+// createImported создаёт пользователей users и пишет в аудит тех, кто создан;
+// у пропущенных из-за занятого email ID остаётся нулевым
+func (s *UserService) createImported(ctx context.Context, users []*domain.User) error {
+	created, err := s.repo.CreateMany(ctx, users)
+	if err != nil {
+		return wrapError(err)
+	}
+	for j, user := range users {
+		if !created[j] {
+			user.ID = 0
+			continue
+		}
+		if err := s.audit.record(ctx, domain.AuditUserCreate, user.ID, nil, user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateImportRow повторяет проверки CreateUser и подставляет роль по умолчанию
+func validateImportRow(row *ImportRow, canChangeRole bool) error {
+	if err := validateAge(row.Age); err != nil {
+		return err
+	}
+	if err := validatePassword(row.Password); err != nil {
+		return err
+	}
+	if row.Role == "" {
+		row.Role = domain.RoleMember
+	}
+	if err := validateRole(row.Role); err != nil {
+		return err
+	}
+	if row.Role != domain.RoleMember && !canChangeRole {
+		return &ForbiddenError{Err: ErrForbidden}
+	}
+	return nil
+}
+
+// hashPasswords хеширует пароли параллельно: bcrypt намеренно медленный,
+// и на тысячах строк последовательное хеширование занимает минуты.
+// После отмены ctx новые пароли не хешируются, и возвращается ошибка ctx.
+func hashPasswords(ctx context.Context, passwords []string) ([]string, error) {
+	hashes := make([]string, len(passwords))
+	errs := make([]error, len(passwords))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range runtime.GOMAXPROCS(0) {
+		wg.Go(func() {
+			for i := range next {
+				hashes[i], errs[i] = auth.HashPassword(passwords[i])
+			}
+		})
+	}
+send:
+	for i := range passwords {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return hashes, nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"api_server/internal/auth"
+	"api_server/internal/domain"
+	"api_server/internal/mail"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// PasswordReset настраивает письма со ссылкой для сброса пароля
+type PasswordReset struct {
+	Tokens *auth.TokenManager
+	Mailer mail.Mailer
+	// URL — страница сброса пароля; токен передаётся в ней параметром token
+	URL string
+	// TTL — срок действия ссылки; по умолчанию auth.DefaultPasswordResetTTL
+	TTL time.Duration
+}
+
+// ForgotPassword отправляет пользователю с адресом email ссылку для сброса пароля.
+// Результат не выдаёт, есть ли такой пользователь: для неизвестного адреса
+// ничего не происходит, а письмо отправляется в фоне, чтобы и время ответа
+// не зависело от адреса. Ошибки аудита и отправки только пишутся в лог.
+func (s *UserService) ForgotPassword(ctx context.Context, email string) (err error) {
+	defer s.observe("forgot_password", &err)
+	if s.reset == nil {
+		return &InternalError{Err: ErrPasswordResetDisabled}
+	}
+	user, err := s.repo.GetByEmail(ctx, email)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return wrapError(err)
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	s.background.Go(func() {
+		if err := s.audit.record(ctx, domain.AuditPasswordResetRequest, user.ID, nil, nil); err != nil {
+			slog.ErrorContext(ctx, "record password reset request", "target_user_id", user.ID, "error", err)
+			return
+		}
+		if err := s.sendPasswordReset(ctx, user); err != nil {
+			slog.ErrorContext(ctx, "send password reset email", "target_user_id", user.ID, "error", err)
+		}
+	})
+	return nil
+}
+
+// ResetPassword устанавливает пароль по токену из письма и отзывает все сессии
+// пользователя. Токен одноразовый: после смены пароля он перестаёт действовать.
+func (s *UserService) ResetPassword(ctx context.Context, token, password string) (err error) {
+	defer s.observe("reset_password", &err)
+	if s.reset == nil {
+		return &InternalError{Err: ErrPasswordResetDisabled}
+	}
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	claims, err := s.reset.Tokens.Parse(token, auth.TokenTypePasswordReset)
+	if err != nil {
+		return invalidToken(err, ErrInvalidResetToken)
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		return invalidToken(err, ErrInvalidResetToken)
+	}
+	user, err := s.repo.GetByID(ctx, userID)
+	if errors.Is(err, ErrNotFound) {
+		return invalidToken(ErrInvalidResetToken, ErrInvalidResetToken)
+	}
+	if err != nil {
+		return wrapError(err)
+	}
+	if claims.Password != passwordFingerprint(user.PasswordHash) {
+		return invalidToken(ErrInvalidResetToken, ErrInvalidResetToken)
+	}
+
+	passwordHash, err := auth.HashPassword(password)
+	if err != nil {
+		return wrapError(err)
+	}
+	// Ссылку открывает сам пользователь: сброс записывается от его имени
+	ctx = auth.WithPrincipal(ctx, auth.Principal{UserID: user.ID, Session: user.SessionVersion + 1})
+	return s.tx.Transaction(ctx, func(ctx context.Context) error {
+		_, err := s.repo.SetPassword(ctx, user.ID, user.PasswordHash, passwordHash, user.SessionVersion)
+		// Пароль уже сменили параллельным запросом с тем же токеном
+		if errors.Is(err, ErrVersionMismatch) || errors.Is(err, ErrNotFound) {
+			return invalidToken(ErrInvalidResetToken, ErrInvalidResetToken)
+		}
+		if err != nil {
+			return wrapError(err)
+		}
+		return s.audit.record(ctx, domain.AuditPasswordReset, user.ID, nil, nil)
+	})
+}
+
+func (s *UserService) sendPasswordReset(ctx context.Context, user *domain.User) error {
+	token, err := s.reset.Tokens.IssuePasswordReset(user.ID, passwordFingerprint(user.PasswordHash), s.reset.TTL)
+	if err != nil {
+		return err
+	}
+	link, err := tokenLink(s.reset.URL, token)
+	if err != nil {
+		return err
+	}
+	return s.reset.Mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Сброс пароля / Password reset",
+		Body: fmt.Sprintf(
+			"Чтобы задать новый пароль, перейдите по ссылке:\n%[1]s\n"+
+				"Если вы не запрашивали сброс пароля, проигнорируйте это письмо.\n\n"+
+				"To set a new password, open the link:\n%[1]s\n"+
+				"If you did not request a password reset, ignore this email.\n",
+			link,
+		),
+	})
+}
+
+// passwordFingerprint — отпечаток хеша пароля для токена сброса: сам хеш
+// в токен не попадает, а после смены пароля отпечаток перестаёт совпадать
+func passwordFingerprint(hash string) string {
+	sum := sha256.Sum256([]byte(hash))
+	return base64.RawURLEncoding.EncodeToString(sum[:16])
+}
@@ -1,43 +1,425 @@
 package service
 
 import (
+	"api_server/internal/auth"
 	"api_server/internal/domain"
 	"api_server/internal/repository"
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
 )
 
 const MinAge = 14
 
+// UserService записывает каждое изменение пользователя в журнал аудита,
+// а перед каждым обновлением сохраняет прежнее состояние в истории версий.
+// Изменение, история и аудит пишутся в одной транзакции: если запись в журнал
+// или историю не удалась, изменение откатывается.
 type UserService struct {
-	repo repository.UserRepositoryInterface
+	repo    repository.UserRepositoryInterface
+	history repository.UserHistoryRepositoryInterface
+	policy  *Policy
+	audit   *AuditService
+	tx      repository.Transactor
+	// verification — письма для подтверждения email; nil отключает подтверждение
+	verification *EmailVerification
+	// reset — письма для сброса пароля; nil отключает сброс
+	reset *PasswordReset
+	// observer получает результат каждой операции; nil отключает наблюдение
+	observer Observer
+	// background — фоновые задачи, например отправка писем (см. Wait)
+	background sync.WaitGroup
 }
 
-func NewUserService(repo repository.UserRepositoryInterface) *UserService {
-	return &UserService{repo: repo}
+// Observer получает результат каждой операции UserService, например для метрик.
+// operation — имя операции в snake_case, err — её ошибка или nil.
+type Observer interface {
+	Observe(operation string, err error)
 }
 
-func (s *UserService) GetUsers() ([]domain.User, error) {
-	return s.repo.GetAll()
+func NewUserService(
+	repo repository.UserRepositoryInterface,
+	history repository.UserHistoryRepositoryInterface,
+	policy *Policy,
+	audit *AuditService,
+	tx repository.Transactor,
+	verification *EmailVerification,
+	reset *PasswordReset,
+	observer Observer,
+) *UserService {
+	return &UserService{
+		repo:         repo,
+		history:      history,
+		policy:       policy,
+		audit:        audit,
+		tx:           tx,
+		verification: verification,
+		reset:        reset,
+		observer:     observer,
+	}
+}
+
+// observe сообщает наблюдателю результат операции; вызывается через defer
+// с указателем на именованный результат err
+func (s *UserService) observe(operation string, err *error) {
+	if s.observer != nil {
+		s.observer.Observe(operation, *err)
+	}
+}
+
+// Wait ждёт завершения фоновых задач сервиса, например отправки писем
+func (s *UserService) Wait() {
+	s.background.Wait()
+}
+
+// Transaction выполняет fn в одной транзакции: изменения, сделанные методами
+// сервиса с контекстом fn, вместе с их историей и аудитом откатываются,
+// если fn вернула ошибку
+func (s *UserService) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.tx.Transaction(ctx, fn)
+}
+
+func (s *UserService) GetUsers(ctx context.Context, query repository.UserQuery) (_ []domain.User, _ int64, err error) {
+	defer s.observe("get_users", &err)
+	if err := s.policy.Authorize(ctx, ActionListUsers, 0); err != nil {
+		return nil, 0, err
+	}
+	users, total, err := s.repo.GetAll(ctx, query)
+	return users, total, wrapError(err)
+}
+
+func (s *UserService) GetUsersByCursor(ctx context.Context, query repository.UserQuery, cursor *repository.Cursor) (_ []domain.User, _ bool, err error) {
+	defer s.observe("get_users_by_cursor", &err)
+	if err := s.policy.Authorize(ctx, ActionListUsers, 0); err != nil {
+		return nil, false, err
+	}
+	users, hasMore, err := s.repo.GetAllByCursor(ctx, query, cursor)
+	return users, hasMore, wrapError(err)
+}
+
+func (s *UserService) GetUserByID(ctx context.Context, ID uint) (_ *domain.User, err error) {
+	defer s.observe("get_user", &err)
+	if err := s.policy.Authorize(ctx, ActionReadUser, ID); err != nil {
+		return nil, err
+	}
+	user, err := s.repo.GetByID(ctx, ID)
+	return user, wrapError(err)
+}
+
+func (s *UserService) GetUserByName(ctx context.Context, name string) (_ *domain.User, err error) {
+	defer s.observe("get_user_by_name", &err)
+	user, err := s.repo.GetByName(ctx, name)
+	return s.authorizeRead(ctx, user, err)
+}
+
+func (s *UserService) GetUserByEmail(ctx context.Context, email string) (_ *domain.User, err error) {
+	defer s.observe("get_user_by_email", &err)
+	user, err := s.repo.GetByEmail(ctx, email)
+	return s.authorizeRead(ctx, user, err)
+}
+
+// authorizeRead проверяет доступ к уже найденному пользователю:
+// при поиске не по ID его ID заранее неизвестен
+func (s *UserService) authorizeRead(ctx context.Context, user *domain.User, err error) (*domain.User, error) {
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if err := s.policy.Authorize(ctx, ActionReadUser, user.ID); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// CreateUser создаёт пользователя с ролью role; пустая роль означает RoleMember.
+// Назначить другую роль может только тот, кому разрешено менять роли.
+func (s *UserService) CreateUser(ctx context.Context, name, email string, age uint, password string, role domain.Role) (_ *domain.User, err error) {
+	defer s.observe("create_user", &err)
+	if err := validateAge(age); err != nil {
+		return nil, err
+	}
+	if err := validatePassword(password); err != nil {
+		return nil, err
+	}
+	if role == "" {
+		role = domain.RoleMember
+	}
+	if err := validateRole(role); err != nil {
+		return nil, err
+	}
+	if err := s.policy.Authorize(ctx, ActionCreateUser, 0); err != nil {
+		return nil, err
+	}
+	if role != domain.RoleMember {
+		if err := s.policy.Authorize(ctx, ActionChangeRole, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	passwordHash, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	var user *domain.User
+	err = s.tx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if user, err = s.repo.Create(ctx, name, email, age, passwordHash, role); err != nil {
+			return wrapError(err)
+		}
+		return s.audit.record(ctx, domain.AuditUserCreate, user.ID, nil, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Письмо уходит в фоне и только после фиксации внешней транзакции (например,
+	// атомарного пакета): при откате ссылка вела бы к несуществующему пользователю.
+	// Без письма пользователь сможет запросить его повторно.
+	if s.verification != nil {
+		s.tx.AfterCommit(ctx, func() {
+			ctx := context.WithoutCancel(ctx)
+			s.background.Go(func() {
+				if err := s.sendVerification(ctx, user, user.Email); err != nil {
+					slog.ErrorContext(ctx, "send verification email", "target_user_id", user.ID, "error", err)
+				}
+			})
+		})
+	}
+	return user, nil
+}
+
+// UpdateUser изменяет пользователя, если его текущая версия равна version
+// (см. domain.User.Version), иначе возвращает PreconditionFailedError.
+// При version == 0 изменяется текущая версия пользователя.
+func (s *UserService) UpdateUser(ctx context.Context, ID uint, name string, age uint, version uint) (_ *domain.User, err error) {
+	defer s.observe("update_user", &err)
+	if err := validateAge(age); err != nil {
+		return nil, err
+	}
+	if err := s.policy.Authorize(ctx, ActionUpdateUser, ID); err != nil {
+		return nil, err
+	}
+	return s.update(ctx, ID, version, func(ctx context.Context, before *domain.User) (*domain.User, error) {
+		return s.repo.Update(ctx, ID, name, age, before.Version)
+	})
 }
 
-func (s *UserService) GetUserByID(ID uint) (*domain.User, error) {
-	return s.repo.GetByID(ID)
+// ChangeRole назначает пользователю роль; version проверяется как в UpdateUser
+func (s *UserService) ChangeRole(ctx context.Context, ID uint, role domain.Role, version uint) (_ *domain.User, err error) {
+	defer s.observe("change_role", &err)
+	if err := validateRole(role); err != nil {
+		return nil, err
+	}
+	if err := s.policy.Authorize(ctx, ActionChangeRole, ID); err != nil {
+		return nil, err
+	}
+	return s.update(ctx, ID, version, func(ctx context.Context, before *domain.User) (*domain.User, error) {
+		return s.repo.SetRole(ctx, ID, role, before.Version)
+	})
+}
+
+// update в одной транзакции читает пользователя (см. getForUpdate), изменяет его
+// функцией fn и сохраняет прежнее состояние в истории и аудите (см. updated)
+func (s *UserService) update(ctx context.Context, ID uint, version uint, fn func(ctx context.Context, before *domain.User) (*domain.User, error)) (*domain.User, error) {
+	var user *domain.User
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		before, err := s.getForUpdate(ctx, ID, version)
+		if err != nil {
+			return err
+		}
+		if user, err = fn(ctx, before); err != nil {
+			return wrapError(err)
+		}
+		return s.updated(ctx, before, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
 }
 
-func (s *UserService) GetUserByName(name string) (*domain.User, error) {
-	return s.repo.GetByName(name)
+// getForUpdate возвращает пользователя перед изменением и проверяет,
+// что его версия равна version, если она задана
+func (s *UserService) getForUpdate(ctx context.Context, ID uint, version uint) (*domain.User, error) {
+	user, err := s.repo.GetByID(ctx, ID)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if version != 0 && user.Version != version {
+		return nil, &PreconditionFailedError{Err: ErrVersionMismatch}
+	}
+	return user, nil
+}
+
+// updated сохраняет прежнее состояние пользователя новой версией в истории
+// и пишет изменение в журнал аудита. Обновление без изменений никуда не пишется.
+func (s *UserService) updated(ctx context.Context, before, after *domain.User) error {
+	if diffUsers(before, after) == nil {
+		return nil
+	}
+	if _, err := s.history.Create(ctx, before); err != nil {
+		return wrapError(err)
+	}
+	return s.audit.record(ctx, domain.AuditUserUpdate, after.ID, before, after)
 }
 
-func (s *UserService) CreateUser(name, email string, age uint) (*domain.User, error) {
+// GetHistory возвращает сохранённые версии пользователя от новых к старым
+func (s *UserService) GetHistory(ctx context.Context, ID uint) (_ []domain.UserVersion, err error) {
+	defer s.observe("get_history", &err)
+	if _, err := s.GetUserByID(ctx, ID); err != nil {
+		return nil, err
+	}
+	versions, err := s.history.GetAll(ctx, ID)
+	return versions, wrapError(err)
+}
+
+func (s *UserService) GetVersion(ctx context.Context, ID, version uint) (_ *domain.UserVersion, err error) {
+	defer s.observe("get_version", &err)
+	if _, err := s.GetUserByID(ctx, ID); err != nil {
+		return nil, err
+	}
+	v, err := s.history.Get(ctx, ID, version)
+	return v, wrapError(err)
+}
+
+// RevertUser возвращает имя и возраст пользователя из версии version.
+// Текущее состояние при этом сохраняется в истории новой версией.
+// Роль и email не возвращаются: они меняются отдельными операциями.
+func (s *UserService) RevertUser(ctx context.Context, ID, version uint) (_ *domain.User, err error) {
+	defer s.observe("revert_user", &err)
+	v, err := s.GetVersion(ctx, ID, version)
+	if err != nil {
+		return nil, err
+	}
+	return s.UpdateUser(ctx, ID, v.Name, v.Age, 0)
+}
+
+// DeleteUser мягко удаляет пользователя; version проверяется как в UpdateUser
+func (s *UserService) DeleteUser(ctx context.Context, ID uint, version uint) (err error) {
+	defer s.observe("delete_user", &err)
+	if err := s.policy.Authorize(ctx, ActionDeleteUser, ID); err != nil {
+		return err
+	}
+	return s.tx.Transaction(ctx, func(ctx context.Context) error {
+		before, err := s.getForUpdate(ctx, ID, version)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.Delete(ctx, ID, before.Version); err != nil {
+			return wrapError(err)
+		}
+		return s.audit.record(ctx, domain.AuditUserDelete, ID, before, nil)
+	})
+}
+
+func (s *UserService) RestoreUser(ctx context.Context, ID uint) (_ *domain.User, err error) {
+	defer s.observe("restore_user", &err)
+	if err := s.policy.Authorize(ctx, ActionRestoreUser, ID); err != nil {
+		return nil, err
+	}
+	var user *domain.User
+	err = s.tx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if user, err = s.repo.Restore(ctx, ID); err != nil {
+			return wrapError(err)
+		}
+		return s.audit.record(ctx, domain.AuditUserRestore, ID, nil, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// PurgeUser удаляет пользователя безвозвратно; восстановить его уже нельзя.
+// При version != 0 пользователь удаляется, только если его версия совпадает.
+func (s *UserService) PurgeUser(ctx context.Context, ID uint, version uint) (err error) {
+	defer s.observe("purge_user", &err)
+	if err := s.policy.Authorize(ctx, ActionPurgeUser, ID); err != nil {
+		return err
+	}
+	return s.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.Purge(ctx, ID, version); err != nil {
+			return wrapError(err)
+		}
+		return s.audit.record(ctx, domain.AuditUserPurge, ID, nil, nil)
+	})
+}
+
+// PurgeDeleted безвозвратно удаляет пользователей, удалённых раньше чем retention назад
+func (s *UserService) PurgeDeleted(ctx context.Context, retention time.Duration) (_ int64, err error) {
+	defer s.observe("purge_deleted", &err)
+	if err := s.policy.Authorize(ctx, ActionPurgeUser, 0); err != nil {
+		return 0, err
+	}
+	var ids []uint
+	err = s.tx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if ids, err = s.repo.PurgeDeletedBefore(ctx, time.Now().Add(-retention)); err != nil {
+			return wrapError(err)
+		}
+		for _, id := range ids {
+			if err := s.audit.record(ctx, domain.AuditUserPurge, id, nil, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(ids)), nil
+}
+
+// RunRetention раз в interval вызывает PurgeDeleted, пока не отменён ctx
+func (s *UserService) RunRetention(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.PurgeDeleted(ctx, retention)
+		if err != nil {
+			slog.ErrorContext(ctx, "purge deleted users", "error", err)
+		} else if purged > 0 {
+			slog.InfoContext(ctx, "purged deleted users", "count", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func validateAge(age uint) error {
 	if age < MinAge {
-		return nil, ErrInvalidAge
+		return &ValidationError{
+			Err:    ErrInvalidAge,
+			Fields: []FieldViolation{{Field: "age", Rule: "min", Err: ErrInvalidAge}},
+		}
 	}
-	return s.repo.Create(name, email, age)
+	return nil
 }
 
-func (s *UserService) UpdateUser(ID uint, name string, age uint) (*domain.User, error) {
-	return s.repo.Update(ID, name, age)
+// validatePassword проверяет длину пароля в байтах: bcrypt не принимает
+// пароли длиннее auth.MaxPasswordLength байт
+func validatePassword(password string) error {
+	if len(password) > auth.MaxPasswordLength {
+		return &ValidationError{
+			Err:    ErrPasswordTooLong,
+			Fields: []FieldViolation{{Field: "password", Rule: "max", Err: ErrPasswordTooLong}},
+		}
+	}
+	return nil
 }
 
-func (s *UserService) DeleteUser(ID uint) error {
-	return s.repo.Delete(ID)
+func validateRole(role domain.Role) error {
+	if !slices.Contains(domain.Roles, role) {
+		return &ValidationError{
+			Err:    ErrInvalidRole,
+			Fields: []FieldViolation{{Field: "role", Rule: "oneof", Err: ErrInvalidRole}},
+		}
+	}
+	return nil
 }
@@ -3,14 +3,46 @@ package main
 import (
 	_ "api_server/docs"
 	"api_server/internal/api"
+	"api_server/internal/auth"
+	"api_server/internal/domain"
+	"api_server/internal/logging"
+	"api_server/internal/mail"
+	"api_server/internal/metrics"
+	"api_server/internal/ratelimit"
 	"api_server/internal/repository"
 	"api_server/internal/repository/memory"
 	"api_server/internal/service"
+	"context"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	gormlogger "gorm.io/gorm/logger"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRequestTimeout = 10 * time.Second
+	// defaultImportTimeout рассчитан на api.MaxImportRows строк: bcrypt со стоимостью
+	// по умолчанию хеширует их на двух ядрах за несколько минут
+	defaultImportTimeout = 5 * time.Minute
+	// defaultExportTimeout — потоковая выгрузка всей базы пачками идёт дольше обычного запроса
+	defaultExportTimeout = 10 * time.Minute
+	// defaultSlowQueryThreshold — запросы к БД дольше этого попадают в лог как медленные
+	defaultSlowQueryThreshold = 200 * time.Millisecond
+	// retentionCheckInterval — как часто удаляются данные с истёкшим сроком хранения:
+	// мягко удалённые пользователи и ключи идемпотентности
+	retentionCheckInterval = time.Hour
+	// exportCheckInterval — как часто проверяются очередь выгрузок и срок хранения их файлов
+	exportCheckInterval = time.Minute
+	// rateLimitCleanupInterval — как часто из памяти удаляются полные корзины ограничений частоты
+	rateLimitCleanupInterval = time.Minute
 )
 
 // @title           Example user API
@@ -22,11 +54,27 @@ import (
 
 // @host      localhost:8080
 // @BasePath  /
+
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 Access-токен в формате "Bearer <token>"
+
+// @securityDefinitions.apikey  ApiKeyAuth
+// @in                          header
+// @name                        X-API-Key
+// @description                 API-ключ для межсервисных вызовов
 func main() {
 	err := godotenv.Load(".env")
 	if err != nil {
 		panic("Error loading .env file")
 	}
+	logLevel, err := logging.ParseLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		panic(err)
+	}
+	// Логи стандартного пакета log тоже попадают в этот логгер
+	slog.SetDefault(logging.New(os.Stdout, logLevel))
 
 	DB := repository.NewDB(
 		os.Getenv("DB_HOST"),
@@ -36,21 +84,182 @@ func main() {
 		os.Getenv("DB_NAME"),
 		os.Getenv("DB_SSL_MODE"),
 	)
-	repo, err := memory.NewUserRepository(DB)
+	DB.Logger = gormlogger.NewSlogLogger(slog.Default(), gormlogger.Config{
+		SlowThreshold:             durationEnv("DB_SLOW_QUERY_THRESHOLD", defaultSlowQueryThreshold),
+		LogLevel:                  gormlogger.Warn,
+		IgnoreRecordNotFoundError: true,
+		// Значения параметров не попадают в лог: среди них хеши паролей и токены
+		ParameterizedQueries: true,
+	})
+	db, err := memory.Open(DB)
+	if err != nil {
+		panic(err)
+	}
+	repo := memory.NewUserRepository(db)
+	m := metrics.New()
+	sqlDB, err := db.DB()
+	if err != nil {
+		panic(err)
+	}
+	if err := m.RegisterDB(os.Getenv("DB_NAME"), sqlDB); err != nil {
+		panic(err)
+	}
+	err = m.RegisterUsersTotal(func(ctx context.Context) (int64, error) {
+		_, total, err := repo.GetAll(ctx, repository.UserQuery{Limit: 1})
+		return total, err
+	})
+	if err != nil {
+		panic(err)
+	}
+	tokens, err := auth.NewTokenManager(
+		os.Getenv("JWT_SECRET"),
+		durationEnv("JWT_ACCESS_TTL", auth.DefaultAccessTTL),
+		durationEnv("JWT_REFRESH_TTL", auth.DefaultRefreshTTL),
+	)
 	if err != nil {
 		panic(err)
 	}
-	s := service.NewUserService(repo)
-	handler := api.NewHandler(s)
 
-	r := gin.Default()
+	policy := service.NewPolicy(repo)
+	audit := service.NewAuditService(memory.NewAuditRepository(db), policy)
+	var verification *service.EmailVerification
+	var reset *service.PasswordReset
+	if mailer := newMailer(); mailer != nil {
+		verification = &service.EmailVerification{
+			Tokens: tokens,
+			Mailer: mailer,
+			URL:    stringEnv("VERIFY_EMAIL_URL", "http://localhost:8080/verify-email"),
+			TTL:    durationEnv("EMAIL_VERIFICATION_TTL", auth.DefaultEmailVerificationTTL),
+		}
+		reset = &service.PasswordReset{
+			Tokens: tokens,
+			Mailer: mailer,
+			URL:    stringEnv("RESET_PASSWORD_URL", "http://localhost:8080/reset-password"),
+			TTL:    durationEnv("PASSWORD_RESET_TTL", auth.DefaultPasswordResetTTL),
+		}
+	} else {
+		slog.Warn("mail is not configured: email verification and password reset are disabled")
+	}
+	s := service.NewUserService(
+		repo,
+		memory.NewUserHistoryRepository(db),
+		policy,
+		audit,
+		memory.NewTransactor(db),
+		verification,
+		reset,
+		m,
+	)
+	if err := ensureAdmin(s, os.Getenv("ADMIN_EMAIL"), os.Getenv("ADMIN_PASSWORD")); err != nil {
+		panic(err)
+	}
+	// USER_RETENTION — сколько хранятся мягко удалённые пользователи; 0 — бессрочно
+	if retention := durationEnv("USER_RETENTION", 0); retention > 0 {
+		go s.RunRetention(context.Background(), retention, retentionCheckInterval)
+	}
+	config := api.Config{
+		MaxPageSize:   intEnv("MAX_PAGE_SIZE", api.MaxPageSize),
+		CacheControl:  os.Getenv("CACHE_CONTROL"),
+		MaxImportRows: intEnv("MAX_IMPORT_ROWS", api.MaxImportRows),
+		MaxBatchSize:  intEnv("MAX_BATCH_SIZE", api.MaxBatchSize),
+	}
+	handler := api.NewHandler(s, config)
+	auditHandler := api.NewAuditHandler(audit, config)
+	authHandler := api.NewAuthHandler(service.NewAuthService(repo, tokens))
+	idempotency := service.NewIdempotencyService(
+		memory.NewIdempotencyRepository(db),
+		durationEnv("IDEMPOTENCY_TTL", service.DefaultIdempotencyTTL),
+		durationEnv("IDEMPOTENCY_LOCK_TIMEOUT", service.DefaultIdempotencyLockTimeout),
+	)
+	go idempotency.RunCleanup(context.Background(), retentionCheckInterval)
+	exports := service.NewExportService(
+		memory.NewExportJobRepository(db),
+		s,
+		policy,
+		stringEnv("EXPORT_DIR", filepath.Join(os.TempDir(), "user-exports")),
+		durationEnv("EXPORT_TTL", service.DefaultExportTTL),
+	)
+	go exports.Run(context.Background(), exportCheckInterval)
+	exportJobHandler := api.NewExportJobHandler(exports, config)
+	keys := service.NewAPIKeyService(memory.NewAPIKeyRepository(db), policy)
+	apiKeyHandler := api.NewAPIKeyHandler(keys)
+
+	limits := ratelimit.NewMemoryStore()
+	go limits.RunCleanup(context.Background(), rateLimitCleanupInterval)
+	// Входу и сбросу пароля — общая корзина на IP против перебора паролей и рассылки писем
+	authLimit := api.RateLimit(limits, "auth", rateLimitEnv("RATE_LIMIT_AUTH", "10/1m"), api.IPKey)
+	// Созданию пользователей — отдельная корзина на пользователя или API-ключ против массовых регистраций.
+	// Импорт и пакет создают до MAX_IMPORT_ROWS и MAX_BATCH_SIZE пользователей за запрос,
+	// поэтому у них свои, более строгие корзины.
+	createLimit := api.RateLimit(limits, "user-create", rateLimitEnv("RATE_LIMIT_USER_CREATE", "30/1h"), api.IdentityKey)
+	importLimit := api.RateLimit(limits, "user-import", rateLimitEnv("RATE_LIMIT_USER_IMPORT", "5/1h"), api.IdentityKey)
+	batchLimit := api.RateLimit(limits, "user-batch", rateLimitEnv("RATE_LIMIT_USER_BATCH", "30/1h"), api.IdentityKey)
+	// Каждый запрос смены email отправляет письмо на произвольный адрес
+	emailLimit := api.RateLimit(limits, "email-change", rateLimitEnv("RATE_LIMIT_EMAIL_CHANGE", "5/1h"), api.IdentityKey)
+
+	r := gin.New()
+	// X-Forwarded-For учитывается только от перечисленных прокси, иначе клиент
+	// подменой заголовка получал бы новую корзину ограничений и чужой IP в аудите
+	if err := r.SetTrustedProxies(listEnv("TRUSTED_PROXIES")); err != nil {
+		panic(err)
+	}
+	r.Use(
+		api.RequestID(),
+		api.Logger(slog.Default()),
+		api.Metrics(m),
+		api.Recovery(),
+		api.Language(os.Getenv("DEFAULT_LANGUAGE")),
+		api.Timeout(durationEnv("REQUEST_TIMEOUT", defaultRequestTimeout), map[string]time.Duration{
+			"/users/import": durationEnv("IMPORT_TIMEOUT", defaultImportTimeout),
+			"/users/export": durationEnv("EXPORT_TIMEOUT", defaultExportTimeout),
+		}),
+	)
+	// Формат ошибок выбирается до ErrorHandler и первого ограничения:
+	// иначе 429 глобального ограничения ушёл бы в обычном формате
+	if os.Getenv("ERROR_FORMAT") == "problem" {
+		r.Use(api.ProblemDetails())
+	}
+	r.Use(
+		api.ErrorHandler(),
+		api.RateLimit(limits, "global", rateLimitEnv("RATE_LIMIT_GLOBAL", "1000/1s"), api.GlobalKey),
+	)
 	r.GET("/ping", handler.Ping)
-	r.GET("/user/:id", handler.GetUser)
-	r.POST("/user", handler.CreateUser)
-	r.PATCH("/user/:id", handler.UpdateUser)
-	r.DELETE("/user/:id", handler.DeleteUser)
+	r.GET("/metrics", gin.WrapH(m.Handler()))
+	r.POST("/auth/login", authLimit, authHandler.Login)
+	r.POST("/auth/refresh", authHandler.Refresh)
+	r.POST("/auth/forgot-password", authLimit, handler.ForgotPassword)
+	r.POST("/auth/reset-password", authLimit, handler.ResetPassword)
+	r.GET("/verify-email", handler.VerifyEmail)
+
+	protected := r.Group("/",
+		api.Authenticate(tokens, keys),
+		api.RateLimit(limits, "identity", rateLimitEnv("RATE_LIMIT_IDENTITY", "600/1m"), api.IdentityKey),
+	)
+	protected.GET("/user/:id", api.RequireScope(auth.ScopeUsersRead), handler.GetUser)
+	protected.POST("/user", api.RequireScope(auth.ScopeUsersWrite), createLimit, api.Idempotency(idempotency), handler.CreateUser)
+	protected.PATCH("/user/:id", api.RequireScope(auth.ScopeUsersWrite), handler.UpdateUser)
+	protected.DELETE("/user/:id", api.RequireScope(auth.ScopeUsersDelete), handler.DeleteUser)
+	protected.POST("/user/:id/restore", api.RequireScope(auth.ScopeUsersDelete), handler.RestoreUser)
+	protected.POST("/user/:id/email", api.RequireScope(auth.ScopeUsersWrite), emailLimit, handler.RequestEmailChange)
+	protected.GET("/user/:id/history", api.RequireScope(auth.ScopeUsersRead), handler.GetUserHistory)
+	protected.GET("/user/:id/history/:version", api.RequireScope(auth.ScopeUsersRead), handler.GetUserVersion)
+	protected.POST("/user/:id/revert/:version", api.RequireScope(auth.ScopeUsersWrite), handler.RevertUser)
 
-	r.GET("/users", handler.GetUsers)
+	protected.GET("/users", api.RequireScope(auth.ScopeUsersRead), handler.GetUsers)
+	protected.GET("/users/export", api.RequireScope(auth.ScopeUsersRead), handler.ExportUsers)
+	protected.POST("/users/import", api.RequireScope(auth.ScopeUsersWrite), importLimit, handler.ImportUsers)
+	protected.POST("/users/batch", api.RequireScope(auth.ScopeUsersWrite), batchLimit, handler.BatchUsers)
+
+	protected.POST("/exports", api.RequireScope(auth.ScopeUsersRead), exportJobHandler.StartExport)
+	protected.GET("/exports/:id", api.RequireScope(auth.ScopeUsersRead), exportJobHandler.GetExport)
+	protected.GET("/exports/:id/file", api.RequireScope(auth.ScopeUsersRead), exportJobHandler.GetExportFile)
+
+	protected.GET("/audit", api.RequireScope(auth.ScopeAuditRead), auditHandler.GetAuditRecords)
+
+	apiKeys := protected.Group("/api-keys", api.RequireScope(auth.ScopeKeysManage))
+	apiKeys.POST("", apiKeyHandler.CreateAPIKey)
+	apiKeys.GET("", apiKeyHandler.GetAPIKeys)
+	apiKeys.DELETE("/:id", apiKeyHandler.RevokeAPIKey)
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	err = r.Run(":" + os.Getenv("API_PORT"))
@@ -58,3 +267,98 @@ func main() {
 		panic(err)
 	}
 }
+
+// ensureAdmin создаёт первого пользователя, от имени которого можно войти
+// и завести остальных: без него к защищённым маршрутам не попасть.
+// Если пользователь уже есть, ему возвращается роль администратора.
+func ensureAdmin(s *service.UserService, email, password string) error {
+	if email == "" || password == "" {
+		return nil
+	}
+
+	ctx := context.Background()
+	user, err := s.GetUserByEmail(ctx, email)
+	if err == nil {
+		if user.Role != domain.RoleAdmin {
+			_, err = s.ChangeRole(ctx, user.ID, domain.RoleAdmin, 0)
+		}
+		return err
+	}
+	if !errors.Is(err, service.ErrNotFound) {
+		return err
+	}
+	_, err = s.CreateUser(ctx, "Administrator", email, service.MinAge, password, domain.RoleAdmin)
+	return err
+}
+
+// newMailer отправляет письма через SMTP, если задан SMTP_HOST, или складывает
+// их в каталог MAIL_OUTBOX_DIR, чтобы при разработке их можно было прочитать.
+// Если не задано ни то ни другое, возвращает nil: письма не отправляются.
+func newMailer() mail.Mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		if dir := os.Getenv("MAIL_OUTBOX_DIR"); dir != "" {
+			return mail.NewOutbox(dir)
+		}
+		return nil
+	}
+	return mail.NewSMTPMailer(
+		host,
+		stringEnv("SMTP_PORT", "587"),
+		os.Getenv("SMTP_USERNAME"),
+		os.Getenv("SMTP_PASSWORD"),
+		stringEnv("MAIL_FROM", "no-reply@localhost"),
+		durationEnv("SMTP_TIMEOUT", mail.DefaultSMTPTimeout),
+	)
+}
+
+func durationEnv(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func stringEnv(name string, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// rateLimitEnv разбирает ограничение частоты вида "100/1m"; "off" снимает ограничение
+func rateLimitEnv(name string, def string) ratelimit.Limit {
+	limit, err := ratelimit.ParseLimit(stringEnv(name, def))
+	if err != nil {
+		panic(err)
+	}
+	return limit
+}
+
+// listEnv разбирает список через запятую; пустая переменная даёт nil
+func listEnv(name string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func intEnv(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		panic(err)
+	}
+	return i
+}